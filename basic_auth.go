@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+)
+
+const defaultBasicAuthRealm = "Restricted"
+
+// BasicAuthOption provides a functional approach to configure the BasicAuth
+// middleware.
+type BasicAuthOption func(*basicAuthHandler)
+
+type basicAuthHandler struct {
+	h     http.Handler
+	check func(user, pass string) bool
+	realm string
+}
+
+// BasicAuth is HTTP middleware that requires HTTP Basic authentication.
+// check is called with the supplied user name and password; requests without
+// credentials, or for which check returns false, receive a 401 Unauthorized
+// response with a WWW-Authenticate challenge.
+//
+// check should compare credentials in constant time. BasicAuthCredentials
+// builds such a function from a static set of credentials.
+//
+// Example:
+//
+//  check := handlers.BasicAuthCredentials(map[string]string{"admin": secret})
+//  http.ListenAndServe(":1123", handlers.BasicAuth(check, handlers.BasicAuthRealm("Admin"))(r))
+func BasicAuth(check func(user, pass string) bool, opts ...BasicAuthOption) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		bh := &basicAuthHandler{
+			h:     h,
+			check: check,
+			realm: defaultBasicAuthRealm,
+		}
+
+		for _, option := range opts {
+			option(bh)
+		}
+
+		return bh
+	}
+}
+
+// BasicAuthRealm sets the realm sent in the WWW-Authenticate challenge. The
+// default is "Restricted".
+func BasicAuthRealm(realm string) BasicAuthOption {
+	return func(bh *basicAuthHandler) {
+		bh.realm = realm
+	}
+}
+
+// BasicAuthCredentials returns a check function for BasicAuth that accepts
+// the given user names and passwords. Comparisons take the same time whether
+// or not the user exists and whatever the length of the password.
+func BasicAuthCredentials(credentials map[string]string) func(user, pass string) bool {
+	hashed := make(map[string][sha256.Size]byte, len(credentials))
+	for user, pass := range credentials {
+		hashed[user] = sha256.Sum256([]byte(pass))
+	}
+
+	return func(user, pass string) bool {
+		want, ok := hashed[user]
+		got := sha256.Sum256([]byte(pass))
+		match := subtle.ConstantTimeCompare(got[:], want[:]) == 1
+		return ok && match
+	}
+}
+
+func (bh *basicAuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	user, pass, ok := r.BasicAuth()
+	if !ok || !bh.check(user, pass) {
+		w.Header().Set("WWW-Authenticate", "Basic realm="+strconv.Quote(bh.realm))
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	bh.h.ServeHTTP(w, r)
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBasicAuth(t *testing.T) {
+	check := BasicAuthCredentials(map[string]string{"admin": "s3cret"})
+	handler := BasicAuth(check, BasicAuthRealm("Admin"))(okHandler)
+
+	tests := []struct {
+		user, pass string
+		set        bool
+		status     int
+	}{
+		{"admin", "s3cret", true, http.StatusOK},
+		{"admin", "wrong", true, http.StatusUnauthorized},
+		{"admin", "s3cre", true, http.StatusUnauthorized},
+		{"nobody", "s3cret", true, http.StatusUnauthorized},
+		{"", "", false, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("GET", "/")
+		if tt.set {
+			r.SetBasicAuth(tt.user, tt.pass)
+		}
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.status; got != want {
+			t.Fatalf("bad status for %q/%q: got %v want %v", tt.user, tt.pass, got, want)
+		}
+
+		challenge := rr.Header().Get("WWW-Authenticate")
+		if tt.status == http.StatusUnauthorized && challenge != `Basic realm="Admin"` {
+			t.Fatalf("bad challenge: got %q", challenge)
+		}
+		if tt.status == http.StatusOK && challenge != "" {
+			t.Fatalf("unexpected challenge: got %q", challenge)
+		}
+	}
+}
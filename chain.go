@@ -0,0 +1,67 @@
+package handlers
+
+import "net/http"
+
+// Chain is an immutable list of middleware. A Chain can be built once and
+// applied to any number of handlers.
+type Chain struct {
+	middlewares []func(http.Handler) http.Handler
+}
+
+// NewChain creates a new Chain from the given middleware. The first
+// middleware is the outermost one, i.e. it sees the request first and the
+// response last.
+//
+// Example:
+//
+//  chain := handlers.NewChain(
+//  	handlers.RecoveryHandler(),
+//  	handlers.CORS(),
+//  	handlers.CompressHandler,
+//  )
+//
+//  r := mux.NewRouter()
+//  r.Handle("/users", chain.Then(UserHandler))
+//  r.Handle("/projects", chain.Then(ProjectHandler))
+func NewChain(middlewares ...func(http.Handler) http.Handler) Chain {
+	return Chain{middlewares: append([]func(http.Handler) http.Handler(nil), middlewares...)}
+}
+
+// Then wraps h with every middleware in the chain and returns the result.
+// If h is nil, http.DefaultServeMux is used.
+func (c Chain) Then(h http.Handler) http.Handler {
+	if h == nil {
+		h = http.DefaultServeMux
+	}
+
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		h = c.middlewares[i](h)
+	}
+
+	return h
+}
+
+// ThenFunc works like Then but takes a http.HandlerFunc.
+func (c Chain) ThenFunc(fn http.HandlerFunc) http.Handler {
+	if fn == nil {
+		return c.Then(nil)
+	}
+
+	return c.Then(fn)
+}
+
+// Append returns a new Chain with the given middleware added after the
+// existing ones. The receiver is left unchanged.
+func (c Chain) Append(middlewares ...func(http.Handler) http.Handler) Chain {
+	combined := make([]func(http.Handler) http.Handler, 0, len(c.middlewares)+len(middlewares))
+	combined = append(combined, c.middlewares...)
+	combined = append(combined, middlewares...)
+
+	return Chain{middlewares: combined}
+}
+
+// Extend returns a new Chain with the middleware of other added after the
+// existing ones. The receiver is left unchanged.
+func (c Chain) Extend(other Chain) Chain {
+	return c.Append(other.middlewares...)
+}
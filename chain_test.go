@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func tagMiddleware(tag string, trace *[]string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*trace = append(*trace, tag)
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestChainOrdering(t *testing.T) {
+	var trace []string
+
+	chain := NewChain(tagMiddleware("a", &trace), tagMiddleware("b", &trace))
+	chain = chain.Append(tagMiddleware("c", &trace))
+	chain = chain.Extend(NewChain(tagMiddleware("d", &trace)))
+
+	chain.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		trace = append(trace, "handler")
+	}).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "/"))
+
+	if got, want := trace, []string{"a", "b", "c", "d", "handler"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("bad order: got %v want %v", got, want)
+	}
+}
+
+func TestChainAppendDoesNotModifyReceiver(t *testing.T) {
+	var trace []string
+
+	base := NewChain(tagMiddleware("a", &trace))
+	base.Append(tagMiddleware("b", &trace))
+
+	base.Then(okHandler).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "/"))
+
+	if got, want := trace, []string{"a"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("bad order: got %v want %v", got, want)
+	}
+}
+
+func TestChainPackageHandlers(t *testing.T) {
+	chain := NewChain(
+		RecoveryHandler(RecoveryLogger(discardLogger{})),
+		CORS(),
+		CompressHandler,
+	)
+
+	panicking := chain.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	rr := httptest.NewRecorder()
+	panicking.ServeHTTP(rr, newRequest("GET", "/"))
+	if got, want := rr.Code, http.StatusInternalServerError; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+
+	// The chain is reusable across handlers.
+	r := newRequest("GET", "/")
+	r.Header.Set("Origin", "http://example.com")
+	r.Header.Set(acceptEncoding, "gzip")
+	rr = httptest.NewRecorder()
+	chain.Then(okHandler).ServeHTTP(rr, r)
+
+	if got, want := rr.Header().Get(corsAllowOriginHeader), "*"; got != want {
+		t.Fatalf("bad header: expected %q to be %q, got %q.", corsAllowOriginHeader, want, got)
+	}
+	gr, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(gr)
+	if got, want := string(body), ok; got != want {
+		t.Fatalf("bad body: got %q want %q", got, want)
+	}
+}
+
+type discardLogger struct{}
+
+func (discardLogger) Println(...interface{}) {}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+type charsetContextKey struct{}
+
+// CharsetHandler is HTTP middleware that negotiates the response charset. It
+// picks the supported charset that best matches the request's Accept-Charset
+// header, honouring q-values, and stores it in the request context where
+// handlers can read it with Charset. Requests without an Accept-Charset header
+// get the first supported charset; requests whose header matches none of the
+// supported charsets receive 406 Not Acceptable.
+//
+// Charset names are compared case-insensitively and stored as given in
+// supported.
+//
+// Example:
+//
+//  charset := handlers.CharsetHandler([]string{"utf-8", "iso-8859-1"})
+//
+//  r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+//  	w.Header().Set("Content-Type", "text/plain; charset="+handlers.Charset(r.Context()))
+//  })
+//  http.ListenAndServe(":1123", charset(r))
+func CharsetHandler(supported []string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var charset string
+			if acceptCharset := r.Header.Get("Accept-Charset"); acceptCharset == "" {
+				if len(supported) > 0 {
+					charset = supported[0]
+				}
+			} else if charset = negotiateCharset(acceptCharset, supported); charset == "" {
+				http.Error(w, http.StatusText(http.StatusNotAcceptable), http.StatusNotAcceptable)
+				return
+			}
+
+			h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), charsetContextKey{}, charset)))
+		})
+	}
+}
+
+// Charset returns the charset negotiated by CharsetHandler for the request
+// whose context is ctx, or an empty string if there is none.
+func Charset(ctx context.Context) string {
+	charset, _ := ctx.Value(charsetContextKey{}).(string)
+	return charset
+}
+
+func negotiateCharset(acceptCharset string, supported []string) string {
+	for _, accepted := range parseQualityList(acceptCharset) {
+		if accepted.value == "*" {
+			if len(supported) > 0 {
+				return supported[0]
+			}
+			continue
+		}
+
+		for _, s := range supported {
+			if strings.EqualFold(s, accepted.value) {
+				return s
+			}
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCharsetHandler(t *testing.T) {
+	tests := []struct {
+		acceptCharset string
+		status        int
+		charset       string
+	}{
+		{"", http.StatusOK, "utf-8"},
+		{"ISO-8859-1", http.StatusOK, "iso-8859-1"},
+		{"utf-8;q=0.5, iso-8859-1;q=0.9", http.StatusOK, "iso-8859-1"},
+		{"shift_jis, *;q=0.1", http.StatusOK, "utf-8"},
+		{"shift_jis, koi8-r", http.StatusNotAcceptable, ""},
+	}
+
+	for _, tt := range tests {
+		var charset string
+		handler := CharsetHandler([]string{"utf-8", "iso-8859-1"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			charset = Charset(r.Context())
+		}))
+
+		r := newRequest("GET", "/")
+		if tt.acceptCharset != "" {
+			r.Header.Set("Accept-Charset", tt.acceptCharset)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.status; got != want {
+			t.Fatalf("bad status for %q: got %v want %v", tt.acceptCharset, got, want)
+		}
+		if charset != tt.charset {
+			t.Fatalf("bad charset for %q: got %q want %q", tt.acceptCharset, charset, tt.charset)
+		}
+	}
+}
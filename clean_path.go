@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// CleanPathOption provides a functional approach to configure the CleanPath
+// middleware.
+type CleanPathOption func(*cleanPathHandler)
+
+type cleanPathHandler struct {
+	h                 http.Handler
+	redirectCode      int
+	keepTrailingSlash bool
+}
+
+// CleanPath is HTTP middleware that normalizes the request path using
+// path.Clean semantics: repeated slashes are collapsed and "." and ".."
+// segments are resolved. By default the request is rewritten in place before
+// it reaches the handler; use CleanPathRedirect to redirect the client to the
+// cleaned path instead.
+//
+// Cleaning operates on the escaped form of the path, so an encoded slash
+// (%2F) is never turned into a path separator. Encoded dots (%2E) are
+// decoded first, so "/foo/%2e%2e/admin" is cleaned to "/admin". Requests
+// whose decoded path would still contain a "." or ".." segment, such as
+// "/a%2F..%2Fb", are rejected with 400 Bad Request.
+//
+// Example:
+//
+//  r := mux.NewRouter()
+//  r.HandleFunc("/users/{id}", UserHandler)
+//
+//  http.ListenAndServe(":1123", handlers.CleanPath(handlers.CleanPathRedirect(301))(r))
+func CleanPath(opts ...CleanPathOption) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		ch := &cleanPathHandler{h: h}
+
+		for _, option := range opts {
+			option(ch)
+		}
+
+		return ch
+	}
+}
+
+// CleanPathRedirect makes CleanPath redirect the client to the cleaned path
+// with the given status code (e.g. 301) rather than rewriting the request.
+func CleanPathRedirect(code int) CleanPathOption {
+	return func(ch *cleanPathHandler) {
+		ch.redirectCode = code
+	}
+}
+
+// CleanPathKeepTrailingSlash keeps a trailing slash on the cleaned path, so
+// "/foo//bar/" becomes "/foo/bar/" instead of "/foo/bar".
+func CleanPathKeepTrailingSlash() CleanPathOption {
+	return func(ch *cleanPathHandler) {
+		ch.keepTrailingSlash = true
+	}
+}
+
+func (ch *cleanPathHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	escaped := r.URL.EscapedPath()
+	if escaped == "" {
+		ch.h.ServeHTTP(w, r)
+		return
+	}
+
+	cleaned := cleanEscapedPath(escaped, ch.keepTrailingSlash)
+	unescaped, err := url.PathUnescape(cleaned)
+	if err != nil || hasDotSegment(unescaped) {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+
+	if cleaned == escaped {
+		ch.h.ServeHTTP(w, r)
+		return
+	}
+
+	if ch.redirectCode != 0 {
+		dest := cleaned
+		if r.URL.RawQuery != "" {
+			dest += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, dest, ch.redirectCode)
+		return
+	}
+
+	r.URL.Path = unescaped
+	r.URL.RawPath = cleaned
+	ch.h.ServeHTTP(w, r)
+}
+
+// cleanEscapedPath returns the canonical form of the escaped path p.
+func cleanEscapedPath(p string, keepTrailingSlash bool) string {
+	if p[0] != '/' {
+		p = "/" + p
+	}
+
+	// "%2E" is equivalent to "." (RFC 3986, section 6.2.2.2), so encoded dot
+	// segments are resolved like plain ones.
+	if strings.Contains(p, "%2") {
+		p = strings.Replace(strings.Replace(p, "%2e", ".", -1), "%2E", ".", -1)
+	}
+
+	cleaned := path.Clean(p)
+	if keepTrailingSlash && cleaned != "/" && strings.HasSuffix(p, "/") {
+		cleaned += "/"
+	}
+
+	return cleaned
+}
+
+// hasDotSegment reports whether the path p has a "." or ".." segment.
+func hasDotSegment(p string) bool {
+	for _, segment := range strings.Split(p, "/") {
+		if segment == "." || segment == ".." {
+			return true
+		}
+	}
+
+	return false
+}
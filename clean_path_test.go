@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCleanPathRewrite(t *testing.T) {
+	tests := []struct {
+		url     string
+		opts    []CleanPathOption
+		path    string
+		escaped string
+	}{
+		{"/foo//bar", nil, "/foo/bar", "/foo/bar"},
+		{"//foo/../bar", nil, "/bar", "/bar"},
+		{"/foo/./bar/", nil, "/foo/bar", "/foo/bar"},
+		{"/foo//bar/", []CleanPathOption{CleanPathKeepTrailingSlash()}, "/foo/bar/", "/foo/bar/"},
+		{"/a%2Fb//c", nil, "/a/b/c", "/a%2Fb/c"},
+		{"/foo/%2e%2e/admin", nil, "/admin", "/admin"},
+		{"/foo/%2E%2e/%2e/admin", nil, "/admin", "/admin"},
+		{"/foo/%2e%2ebar", nil, "/foo/..bar", "/foo/..bar"},
+	}
+
+	for _, tt := range tests {
+		var gotPath, gotEscaped string
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotPath = r.URL.Path
+			gotEscaped = r.URL.EscapedPath()
+		})
+
+		CleanPath(tt.opts...)(handler).ServeHTTP(httptest.NewRecorder(), newRequest("GET", tt.url))
+
+		if gotPath != tt.path {
+			t.Fatalf("bad path for %q: got %q want %q", tt.url, gotPath, tt.path)
+		}
+		if gotEscaped != tt.escaped {
+			t.Fatalf("bad escaped path for %q: got %q want %q", tt.url, gotEscaped, tt.escaped)
+		}
+	}
+}
+
+func TestCleanPathRejectsEncodedDotSegments(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatalf("request with dot segments reached the handler as %q", r.URL.Path)
+	})
+
+	for _, url := range []string{"/a%2F..%2Fb", "/a%2F%2e%2e%2Fb", "/a/.%2Fb"} {
+		rr := httptest.NewRecorder()
+		CleanPath()(handler).ServeHTTP(rr, newRequest("GET", url))
+
+		if got, want := rr.Code, http.StatusBadRequest; got != want {
+			t.Fatalf("bad status for %q: got %v want %v", url, got, want)
+		}
+	}
+}
+
+func TestCleanPathRedirect(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("request with unclean path must not reach the handler")
+	})
+
+	rr := httptest.NewRecorder()
+	CleanPath(CleanPathRedirect(http.StatusMovedPermanently))(handler).ServeHTTP(rr, newRequest("GET", "/foo//bar/../baz?q=1"))
+
+	if got, want := rr.Code, http.StatusMovedPermanently; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+	if got, want := rr.Header().Get("Location"), "/foo/baz?q=1"; got != want {
+		t.Fatalf("bad location: got %q want %q", got, want)
+	}
+
+	rr = httptest.NewRecorder()
+	CleanPath(CleanPathRedirect(http.StatusMovedPermanently))(okHandler).ServeHTTP(rr, newRequest("GET", "/foo/bar"))
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultClientCertHeader is the header read by ClientCertAllowlist when
+// TrustForwardedClientCert is set without a header name.
+const DefaultClientCertHeader = "X-Client-Cert"
+
+type clientIdentityContextKey struct{}
+
+// ClientCertOption provides a functional approach to configure the
+// ClientCertAllowlist middleware.
+type ClientCertOption func(*clientCertHandler)
+
+type clientCertHandler struct {
+	h               http.Handler
+	allowed         []string
+	forwardedHeader string
+}
+
+// ClientCertAllowlist is HTTP middleware that authorizes requests by the
+// client certificate presented over mutual TLS. The request is served only if
+// the subject common name or one of the DNS, email or URI subject alternative
+// names of the certificate is in allowed; otherwise, or without a client
+// certificate, it receives 403 Forbidden. The matched name is available to
+// handlers through ClientIdentity.
+//
+// The certificate itself must be verified by the TLS server, for example by
+// setting tls.Config.ClientAuth to tls.RequireAndVerifyClientCert.
+//
+// Example:
+//
+//  allow := handlers.ClientCertAllowlist([]string{"billing.internal", "spiffe://example.org/billing"})
+//  r.Handle("/internal/", allow(InternalHandler))
+func ClientCertAllowlist(allowed []string, opts ...ClientCertOption) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		ch := &clientCertHandler{
+			h:       h,
+			allowed: allowed,
+		}
+
+		for _, option := range opts {
+			option(ch)
+		}
+
+		return ch
+	}
+}
+
+// TrustForwardedClientCert makes ClientCertAllowlist read the client
+// certificate from the named request header, for servers behind a proxy that
+// terminates mutual TLS. The header holds the PEM-encoded certificate, which
+// may be percent-encoded as with nginx's $ssl_client_escaped_cert; a "+" is
+// part of the base64 body, not an encoded space. An empty name uses
+// X-Client-Cert.
+//
+// Only use this option when the proxy verifies the certificate and always
+// sets or clears the header, since clients can otherwise supply it
+// themselves.
+func TrustForwardedClientCert(header string) ClientCertOption {
+	return func(ch *clientCertHandler) {
+		if header == "" {
+			header = DefaultClientCertHeader
+		}
+		ch.forwardedHeader = header
+	}
+}
+
+// ClientIdentity returns the certificate name matched by ClientCertAllowlist
+// for the request whose context is ctx, or an empty string if there is none.
+func ClientIdentity(ctx context.Context) string {
+	identity, _ := ctx.Value(clientIdentityContextKey{}).(string)
+	return identity
+}
+
+func (ch *clientCertHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cert := ch.certificate(r)
+	if cert == nil {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	identity, ok := ch.match(cert)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	ch.h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIdentityContextKey{}, identity)))
+}
+
+// certificate returns the client certificate of r, or nil if there is none.
+func (ch *clientCertHandler) certificate(r *http.Request) *x509.Certificate {
+	if ch.forwardedHeader == "" {
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			return nil
+		}
+		return r.TLS.PeerCertificates[0]
+	}
+
+	value := r.Header.Get(ch.forwardedHeader)
+	if strings.Contains(value, "%") {
+		unescaped, err := url.PathUnescape(value)
+		if err != nil {
+			return nil
+		}
+		value = unescaped
+	}
+
+	block, _ := pem.Decode([]byte(value))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil
+	}
+
+	return cert
+}
+
+// match returns the first name of cert that is in the allowlist.
+func (ch *clientCertHandler) match(cert *x509.Certificate) (string, bool) {
+	names := []string{cert.Subject.CommonName}
+	names = append(names, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		names = append(names, u.String())
+	}
+
+	for _, name := range names {
+		if name != "" && isMatch(name, ch.allowed) {
+			return name, true
+		}
+	}
+
+	return "", false
+}
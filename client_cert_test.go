@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newClientCert(t *testing.T, commonName string, dnsNames ...string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestClientCertAllowlist(t *testing.T) {
+	var identity string
+	handler := ClientCertAllowlist([]string{"billing", "api.billing.internal"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity = ClientIdentity(r.Context())
+	}))
+
+	tests := []struct {
+		name     string
+		cert     *x509.Certificate
+		status   int
+		identity string
+	}{
+		{"common name", newClientCert(t, "billing"), http.StatusOK, "billing"},
+		{"dns name", newClientCert(t, "other", "api.billing.internal"), http.StatusOK, "api.billing.internal"},
+		{"not allowed", newClientCert(t, "reports", "api.reports.internal"), http.StatusForbidden, ""},
+		{"no certificate", nil, http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		identity = ""
+		r := newRequest("GET", "https://www.example.com/")
+		r.TLS = &tls.ConnectionState{}
+		if tt.cert != nil {
+			r.TLS.PeerCertificates = []*x509.Certificate{tt.cert}
+		}
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.status; got != want {
+			t.Fatalf("%s: bad status: got %v want %v", tt.name, got, want)
+		}
+		if got, want := identity, tt.identity; got != want {
+			t.Fatalf("%s: bad identity: got %q want %q", tt.name, got, want)
+		}
+	}
+}
+
+func TestClientCertAllowlistForwarded(t *testing.T) {
+	handler := ClientCertAllowlist([]string{"billing"}, TrustForwardedClientCert(""))(okHandler)
+
+	encode := func(cert *x509.Certificate) string {
+		return url.PathEscape(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})))
+	}
+
+	tests := []struct {
+		value  string
+		status int
+	}{
+		{encode(newClientCert(t, "billing")), http.StatusOK},
+		{encode(newClientCert(t, "reports")), http.StatusForbidden},
+		{plusEncodedClientCert(t, "billing"), http.StatusOK},
+		{"garbage", http.StatusForbidden},
+		{"", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("GET", "http://www.example.com/")
+		r.Header.Set(DefaultClientCertHeader, tt.value)
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.status; got != want {
+			t.Fatalf("bad status: got %v want %v", got, want)
+		}
+	}
+}
+
+// plusEncodedClientCert returns a percent-encoded PEM certificate for
+// commonName whose base64 body contains a literal "+", as forwarded by
+// proxies that only escape the characters unsafe in a path.
+func plusEncodedClientCert(t *testing.T, commonName string) string {
+	for i := 0; i < 100; i++ {
+		cert := newClientCert(t, commonName)
+		value := url.PathEscape(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})))
+		if strings.Contains(value, "+") {
+			return value
+		}
+	}
+
+	t.Fatal("no certificate encoding contains +")
+	return ""
+}
@@ -7,6 +7,7 @@ package handlers
 import (
 	"compress/flate"
 	"compress/gzip"
+	"context"
 	"io"
 	"net/http"
 	"strings"
@@ -16,17 +17,61 @@ import (
 
 const acceptEncoding string = "Accept-Encoding"
 
+type compressionContextKey struct{}
+
+// compressionState is stored in the request context by the compression
+// handler so that handlers further down the chain can opt out.
+type compressionState struct {
+	disabled bool
+}
+
+// DisableCompression turns off compression for the response to the request
+// whose context is ctx, even when the client accepts a compressed encoding.
+// It must be called before the response headers or body are written, and has
+// no effect if ctx does not come from a request served by CompressHandler.
+func DisableCompression(ctx context.Context) {
+	if state, ok := ctx.Value(compressionContextKey{}).(*compressionState); ok {
+		state.disabled = true
+	}
+}
+
 type compressResponseWriter struct {
 	compressor io.Writer
 	w          http.ResponseWriter
+	state      *compressionState
+	decided    bool
+	bypass     bool
+}
+
+// passThrough reports whether the response should be written uncompressed.
+// The decision is taken the first time the response is written to.
+func (cw *compressResponseWriter) passThrough() bool {
+	if !cw.decided {
+		cw.decided = true
+		if cw.state != nil && cw.state.disabled {
+			cw.bypass = true
+			cw.w.Header().Del("Content-Encoding")
+		}
+	}
+
+	return cw.bypass
 }
 
 func (cw *compressResponseWriter) WriteHeader(c int) {
+	if cw.passThrough() {
+		cw.w.WriteHeader(c)
+		return
+	}
+
 	cw.w.Header().Del("Content-Length")
 	cw.w.WriteHeader(c)
 }
 
 func (cw *compressResponseWriter) Write(b []byte) (int, error) {
+	if cw.passThrough() {
+		return cw.w.Write(b)
+	}
+
 	h := cw.w.Header()
 	if h.Get("Content-Type") == "" {
 		h.Set("Content-Type", http.DetectContentType(b))
@@ -37,6 +82,10 @@ func (cw *compressResponseWriter) Write(b []byte) (int, error) {
 }
 
 func (cw *compressResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if cw.passThrough() {
+		return io.Copy(cw.w, r)
+	}
+
 	return io.Copy(cw.compressor, r)
 }
 
@@ -46,7 +95,7 @@ type flusher interface {
 
 func (w *compressResponseWriter) Flush() {
 	// Flush compressed data if compressor supports it.
-	if f, ok := w.compressor.(flusher); ok {
+	if f, ok := w.compressor.(flusher); ok && !w.passThrough() {
 		f.Flush()
 	}
 	// Flush HTTP response.
@@ -58,6 +107,9 @@ func (w *compressResponseWriter) Flush() {
 // CompressHandler gzip compresses HTTP responses for clients that support it
 // via the 'Accept-Encoding' header.
 //
+// Handlers can opt out of compression for a single response by calling
+// DisableCompression with the request context.
+//
 // Compressing TLS traffic may leak the page contents to an attacker if the
 // page contains user input: http://security.stackexchange.com/a/102015/12208
 func CompressHandler(h http.Handler) http.Handler {
@@ -113,15 +165,24 @@ func CompressHandlerLevel(h http.Handler, level int) http.Handler {
 		} else if encoding == flateEncoding {
 			encWriter, _ = flate.NewWriter(w, level)
 		}
-		defer encWriter.Close()
-
 		w.Header().Set("Content-Encoding", encoding)
 		r.Header.Del(acceptEncoding)
 
+		state := &compressionState{}
+		r = r.WithContext(context.WithValue(r.Context(), compressionContextKey{}, state))
+
 		cw := &compressResponseWriter{
 			w:          w,
 			compressor: encWriter,
+			state:      state,
 		}
+		defer func() {
+			// Closing the compressor writes the stream footer, which must
+			// not end up in an uncompressed response.
+			if !cw.passThrough() {
+				encWriter.Close()
+			}
+		}()
 
 		w = httpsnoop.Wrap(w, httpsnoop.Hooks{
 			Write: func(httpsnoop.WriteFunc) httpsnoop.WriteFunc {
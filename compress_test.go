@@ -298,3 +298,23 @@ func TestCompressHandlerDoesntInventInterfaces(t *testing.T) {
 	r.Header.Set(acceptEncoding, "gzip")
 	h.ServeHTTP(rw, r)
 }
+
+func TestCompressHandlerDisableCompression(t *testing.T) {
+	handler := CompressHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		DisableCompression(r.Context())
+		w.Header().Set("Content-Type", "video/mp4")
+		w.Write([]byte("already compressed"))
+	}))
+
+	r := newRequest("GET", "/")
+	r.Header.Set(acceptEncoding, "gzip")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+
+	if got := rr.Header().Get("Content-Encoding"); got != "" {
+		t.Fatalf("bad Content-Encoding: got %q want none", got)
+	}
+	if got, want := rr.Body.String(), "already compressed"; got != want {
+		t.Fatalf("bad body: got %q want %q", got, want)
+	}
+}
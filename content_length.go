@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/felixge/httpsnoop"
+)
+
+// ContentLength is HTTP middleware that buffers responses of up to maxBytes
+// so that it can set their Content-Length header, sparing clients that
+// dislike chunked transfer encoding. Larger responses, and responses the
+// handler flushes explicitly, are streamed as they are written, without a
+// Content-Length header unless the handler set one.
+//
+// When combined with CompressHandler, ContentLength must wrap it, so that the
+// length of the compressed body is used:
+//
+//  http.ListenAndServe(":1123", handlers.ContentLength(64<<10)(handlers.CompressHandler(r)))
+func ContentLength(maxBytes int) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cw := &contentLengthWriter{w: w, max: maxBytes, head: r.Method == "HEAD"}
+			h.ServeHTTP(cw.wrap(), r)
+			cw.finish()
+		})
+	}
+}
+
+// contentLengthWriter holds back a response until it is complete or grows
+// larger than max.
+type contentLengthWriter struct {
+	w         http.ResponseWriter
+	max       int
+	head      bool
+	status    int
+	buf       bytes.Buffer
+	streaming bool
+}
+
+func (cw *contentLengthWriter) wrap() http.ResponseWriter {
+	return httpsnoop.Wrap(cw.w, httpsnoop.Hooks{
+		WriteHeader: func(httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
+			return cw.WriteHeader
+		},
+		Write: func(httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+			return cw.Write
+		},
+		ReadFrom: func(httpsnoop.ReadFromFunc) httpsnoop.ReadFromFunc {
+			return func(src io.Reader) (int64, error) {
+				return io.Copy(writerOnly{cw}, src)
+			}
+		},
+		Flush: func(next httpsnoop.FlushFunc) httpsnoop.FlushFunc {
+			return func() {
+				cw.stream()
+				next()
+			}
+		},
+	})
+}
+
+func (cw *contentLengthWriter) WriteHeader(code int) {
+	if cw.streaming {
+		cw.w.WriteHeader(code)
+		return
+	}
+	if cw.status == 0 {
+		cw.status = code
+	}
+}
+
+func (cw *contentLengthWriter) Write(p []byte) (int, error) {
+	if cw.streaming {
+		return cw.w.Write(p)
+	}
+
+	if cw.buf.Len()+len(p) > cw.max {
+		cw.stream()
+		return cw.w.Write(p)
+	}
+
+	return cw.buf.Write(p)
+}
+
+// stream sends what has been buffered and passes later writes through.
+func (cw *contentLengthWriter) stream() {
+	if cw.streaming {
+		return
+	}
+	cw.streaming = true
+
+	if cw.status != 0 {
+		cw.w.WriteHeader(cw.status)
+	}
+	if cw.buf.Len() > 0 {
+		cw.w.Write(cw.buf.Bytes())
+		cw.buf.Reset()
+	}
+}
+
+// finish sends a buffered response with its Content-Length.
+func (cw *contentLengthWriter) finish() {
+	if cw.streaming {
+		return
+	}
+
+	status := cw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	if bodyAllowedForStatus(status) && !cw.head && cw.w.Header().Get("Content-Length") == "" {
+		cw.w.Header().Set("Content-Length", strconv.Itoa(cw.buf.Len()))
+	}
+
+	cw.streaming = true
+	cw.w.WriteHeader(status)
+	if cw.buf.Len() > 0 {
+		cw.w.Write(cw.buf.Bytes())
+	}
+}
+
+// writerOnly hides the ReadFrom method of a writer so that io.Copy uses its
+// Write method.
+type writerOnly struct {
+	io.Writer
+}
+
+// bodyAllowedForStatus reports whether a response with status may have a
+// body.
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent, status == http.StatusNotModified:
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestContentLength(t *testing.T) {
+	tests := []struct {
+		body          string
+		contentLength string
+	}{
+		{"small", "5"},
+		{"", "0"},
+		{strings.Repeat("x", 100), ""},
+	}
+
+	for _, tt := range tests {
+		body := tt.body
+		handler := ContentLength(10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+			for i := 0; i < len(body); i += 4 {
+				end := i + 4
+				if end > len(body) {
+					end = len(body)
+				}
+				w.Write([]byte(body[i:end]))
+			}
+		}))
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, newRequest("GET", "/"))
+
+		if got, want := rr.Code, http.StatusAccepted; got != want {
+			t.Fatalf("bad status: got %v want %v", got, want)
+		}
+		if got := rr.Body.String(); got != tt.body {
+			t.Fatalf("bad body: got %q want %q", got, tt.body)
+		}
+		if got := rr.Header().Get("Content-Length"); got != tt.contentLength {
+			t.Fatalf("bad Content-Length for a %d byte body: got %q want %q", len(tt.body), got, tt.contentLength)
+		}
+	}
+}
+
+func TestContentLengthFlushStreams(t *testing.T) {
+	handler := ContentLength(1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("event"))
+		w.(http.Flusher).Flush()
+		w.Write([]byte("event"))
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newRequest("GET", "/"))
+
+	if !rr.Flushed {
+		t.Fatal("expected the response to be flushed")
+	}
+	if got := rr.Header().Get("Content-Length"); got != "" {
+		t.Fatalf("unexpected Content-Length: %q", got)
+	}
+	if got, want := rr.Body.String(), "eventevent"; got != want {
+		t.Fatalf("bad body: got %q want %q", got, want)
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrInvalidCookie is returned by a CookieCodec when a cookie value has been
+// tampered with or is malformed.
+var ErrInvalidCookie = errors.New("handlers: invalid cookie value")
+
+// CookieCodec encodes and decodes cookie values. Implementations may sign
+// values, encrypt them, or both. The cookie name is passed so that a value
+// cannot be moved from one cookie to another.
+type CookieCodec interface {
+	Encode(name string, value []byte) (string, error)
+	Decode(name, value string) ([]byte, error)
+}
+
+type hmacCookieCodec struct {
+	key []byte
+}
+
+// NewHMACCookieCodec returns a CookieCodec that signs values with
+// HMAC-SHA256 using key. Values are signed, not encrypted, so they remain
+// readable by the client.
+func NewHMACCookieCodec(key []byte) CookieCodec {
+	return hmacCookieCodec{key: key}
+}
+
+func (c hmacCookieCodec) Encode(name string, value []byte) (string, error) {
+	payload := base64.RawURLEncoding.EncodeToString(value)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(c.sign(name, payload)), nil
+}
+
+func (c hmacCookieCodec) Decode(name, value string) ([]byte, error) {
+	i := strings.LastIndex(value, ".")
+	if i == -1 {
+		return nil, ErrInvalidCookie
+	}
+
+	payload := value[:i]
+	signature, err := base64.RawURLEncoding.DecodeString(value[i+1:])
+	if err != nil || !hmac.Equal(signature, c.sign(name, payload)) {
+		return nil, ErrInvalidCookie
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrInvalidCookie
+	}
+
+	return decoded, nil
+}
+
+func (c hmacCookieCodec) sign(name, payload string) []byte {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(name))
+	mac.Write([]byte{0})
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+type signedCookieContextKey struct {
+	name string
+}
+
+// SignedCookie is HTTP middleware that decodes the cookie called name with
+// codec before the request reaches the handler. The decoded value is stored
+// in the request context and can be read with SignedCookieValue. Requests
+// whose cookie fails to decode receive 401 Unauthorized. Requests without
+// the cookie are passed through unchanged.
+//
+// Example:
+//
+//  codec := handlers.NewHMACCookieCodec(key)
+//
+//  r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+//  	session, ok := handlers.SignedCookieValue(r.Context(), "session")
+//  	...
+//  })
+//  http.ListenAndServe(":1123", handlers.SignedCookie("session", codec)(r))
+func SignedCookie(name string, codec CookieCodec) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cookie, err := r.Cookie(name)
+			if err != nil {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			value, err := codec.Decode(name, cookie.Value)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), signedCookieContextKey{name}, value)))
+		})
+	}
+}
+
+// SignedCookieValue returns the value of the cookie called name decoded by
+// SignedCookie, and whether the request carried that cookie.
+func SignedCookieValue(ctx context.Context, name string) ([]byte, bool) {
+	value, ok := ctx.Value(signedCookieContextKey{name}).([]byte)
+	return value, ok
+}
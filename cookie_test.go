@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSignedCookie(t *testing.T) {
+	codec := NewHMACCookieCodec([]byte("secret"))
+	valid, _ := codec.Encode("session", []byte("user-42"))
+	otherName, _ := codec.Encode("other", []byte("user-42"))
+
+	tests := []struct {
+		name   string
+		cookie string
+		code   int
+		value  string
+		found  bool
+	}{
+		{"valid", valid, http.StatusOK, "user-42", true},
+		{"tampered", "dXNlci0xMw" + valid[len("dXNlci00Mg"):], http.StatusUnauthorized, "", false},
+		{"wrong name", otherName, http.StatusUnauthorized, "", false},
+		{"malformed", "garbage", http.StatusUnauthorized, "", false},
+		{"absent", "", http.StatusOK, "", false},
+	}
+
+	for _, tt := range tests {
+		var value []byte
+		var found bool
+		handler := SignedCookie("session", codec)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			value, found = SignedCookieValue(r.Context(), "session")
+		}))
+
+		r := newRequest("GET", "/")
+		if tt.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: "session", Value: tt.cookie})
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.code; got != want {
+			t.Fatalf("%s: bad status: got %v want %v", tt.name, got, want)
+		}
+		if found != tt.found || string(value) != tt.value {
+			t.Fatalf("%s: bad value: got %q, %v want %q, %v", tt.name, value, found, tt.value, tt.found)
+		}
+	}
+}
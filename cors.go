@@ -1,9 +1,19 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"path"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // CORSOption represents a functional option for configuring the CORS middleware.
@@ -13,24 +23,90 @@ type cors struct {
 	h                      http.Handler
 	allowedHeaders         []string
 	allowedHeadersFunc     func(r *http.Request) []string
+	deniedHeaders          []string
+	reflectRequestHeaders  bool
+	maxRequestedHeaders    int
 	allowedMethods         []string
+	allowedMethodsFunc     func(r *http.Request) []string
+	listAllowedMethods     bool
+	deniedMethods          []string
 	allowedOrigins         []string
-	allowedOriginsFunc     func(r *http.Request) []string
+	allowedOriginsFunc     func(r *http.Request) ([]string, error)
+	safeMethodOrigins      []string
 	allowedOriginValidator OriginValidator
+	originValidators       []OriginValidator
+	originDecider          OriginDecider
+	deniedOrigins          []string
+	allowedOriginSuffixes  []string
+	allowHTTPSuffixes      bool
+	allowedOriginGlobs     []string
+	ignoreWWWPrefix        bool
+	allowedOriginNets      []*net.IPNet
+	allowLocalhost         bool
+	originPolicies         map[string]OriginPolicy
+	allowPrivateNetwork    bool
+	privateNetworkTrusted  []func(r *http.Request, origin string) bool
+	allowNullOrigin        bool
+	forbidNullOrigin       bool
 	exposedHeaders         []string
+	exposedHeadersFunc     func(r *http.Request) []string
 	maxAge                 int
+	requestedMaxAge        int
 	ignoreOptions          bool
+	simplePreflightStatus  int
+	passSimplePreflight    bool
+	headersOnly            bool
+	allowOriginOnSuccess   bool
+	exposeOnlyWithBody     bool
+	healthCheckUserAgents  []string
+	healthCheckPaths       []string
+	fetchSites             []string
+	allowMissingFetchSite  bool
+	enforceAllowOrigin     bool
+	emptyOriginOnDisallow  bool
+	preserveHeaderCase     bool
+	foldRequestedHeaders   bool
+	splitHeaderValues      bool
+	varyHeaders            []string
+	lowercaseOrigin        bool
+	reflectTransform       func(origin string) string
 	allowCredentials       bool
+	allowCredentialsFunc   func(r *http.Request) bool
+	credentialsActual      bool
+	preflightCredentials   func(r *http.Request) bool
 	allowDefaultOrigins    bool
 	defaultOrigin          string
 	optionStatusCode       int
+	optionStatusCodeFunc   func(r *http.Request) int
+	preflightBody          *string
+	audit                  CORSAuditFunc
+	wildcardOnly           bool
+	logger                 RecoveryHandlerLogger
+	strict                 bool
 }
 
 // OriginValidator takes an origin string and returns whether or not that origin is allowed.
 type OriginValidator func(string) bool
 
+// OriginDecision is the result of an OriginDecider.
+type OriginDecision int
+
+const (
+	// OriginAllow allows the origin.
+	OriginAllow OriginDecision = iota
+	// OriginDeny denies the origin.
+	OriginDeny
+)
+
+// OriginDecider is an advanced form of OriginValidator. It decides whether
+// origin is allowed for the request and, when denying, may return a handler
+// that writes the response instead of the middleware.
+type OriginDecider func(r *http.Request, origin string) (OriginDecision, http.HandlerFunc)
+
 var (
+	corsMaxAgeLimit             = 600
 	defaultCorsOptionStatusCode = 200
+	defaultMaxRequestedHeaders  = 100
 	defaultCorsMethods          = []string{"GET", "HEAD", "POST"}
 	defaultCorsHeaders          = []string{"Accept", "Accept-Language", "Content-Language", "Origin"}
 	// (WebKit/Safari v9 sends the Origin header by default in AJAX requests)
@@ -46,16 +122,91 @@ const (
 	corsAllowCredentialsHeader string = "Access-Control-Allow-Credentials"
 	corsRequestMethodHeader    string = "Access-Control-Request-Method"
 	corsRequestHeadersHeader   string = "Access-Control-Request-Headers"
+	corsRequestPrivateNetwork  string = "Access-Control-Request-Private-Network"
+	corsAllowPrivateNetwork    string = "Access-Control-Allow-Private-Network"
+	corsFetchSiteHeader        string = "Sec-Fetch-Site"
 	corsOriginHeader           string = "Origin"
 	corsVaryHeader             string = "Vary"
 	corsOriginMatchAll         string = "*"
+	corsOriginNull             string = "null"
+	corsSubdomainWildcard      string = "*."
+	corsMethodMatchAll         string = "*"
 )
 
+// CORSAuditFunc receives the CORS response headers emitted for a request,
+// along with the origin if it was allowed.
+type CORSAuditFunc func(r *http.Request, matchedOrigin string, headers http.Header)
+
 func (ch *cors) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// WebSocket handshakes are not subject to CORS; hand them to the handler
+	// untouched so the connection can be hijacked.
+	if isWebSocketUpgrade(r) {
+		ch.h.ServeHTTP(w, r)
+		return
+	}
+
+	if ch.fetchSites != nil && !ch.headersOnly && !ch.isFetchSiteAllowed(r) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
 	origin := r.Header.Get(corsOriginHeader)
-	if !ch.isOriginAllowed(r, origin) {
+
+	if origin == "" && r.Method == corsOptionMethod && ch.isHealthCheck(r) {
+		ch.writeHealthCheck(w, r)
+		return
+	}
+
+	// With a pure "*" configuration every real origin is allowed with the
+	// same response, so preflights need not go through the origin checks.
+	if ch.wildcardOnly && r.Method == corsOptionMethod && origin != "" && origin != corsOriginMatchAll {
+		ch.serve(w, r, origin, ch.allowedOrigins, true, nil)
+		return
+	}
+
+	// The allowed origins are loaded once and shared by the origin check and
+	// the response headers. If they cannot be loaded the origin is denied.
+	var allowedOrigins []string
+	allowed, deny := false, http.HandlerFunc(nil)
+	if origin != "" {
+		var err error
+		if allowedOrigins, err = ch.getAllowedOrigins(r); err == nil {
+			allowed, deny = ch.checkOrigin(r, origin, allowedOrigins)
+		}
+	}
+
+	if ch.audit == nil {
+		ch.serve(w, r, origin, allowedOrigins, allowed, deny)
+		return
+	}
+
+	matchedOrigin := ""
+	if allowed {
+		matchedOrigin = origin
+	}
+
+	header := w.Header()
+	ww, done := onWriteHeader(w, func(int) {
+		ch.audit(r, matchedOrigin, corsResponseHeaders(header))
+	})
+	// Deferred so that requests whose handler panics are audited too.
+	defer done()
+	ch.serve(ww, r, origin, allowedOrigins, allowed, deny)
+}
+
+func (ch *cors) serve(w http.ResponseWriter, r *http.Request, origin string, allowedOrigins []string, allowed bool, deny http.HandlerFunc) {
+	if !allowed {
+		if deny != nil && !ch.headersOnly {
+			deny(w, r)
+			return
+		}
+
+		if origin != "" && ch.emptyOriginOnDisallow {
+			w.Header().Set(corsAllowOriginHeader, "")
+		}
+
 		if r.Method != corsOptionMethod || ch.ignoreOptions {
-			ch.h.ServeHTTP(w, r)
+			ch.next(w, r)
 		}
 
 		return
@@ -63,92 +214,260 @@ func (ch *cors) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	if r.Method == corsOptionMethod {
 		if ch.ignoreOptions {
-			ch.h.ServeHTTP(w, r)
+			ch.next(w, r)
 			return
 		}
 
 		if _, ok := r.Header[corsRequestMethodHeader]; !ok {
-			w.WriteHeader(http.StatusBadRequest)
+			ch.rejectPreflight(w, r, http.StatusBadRequest)
 			return
 		}
 
+		if ch.isUnnecessaryPreflight(r) {
+			if ch.passSimplePreflight {
+				ch.next(w, r)
+				return
+			}
+			if ch.simplePreflightStatus != 0 {
+				ch.setAllowOrigin(w, r, origin, allowedOrigins)
+				w.WriteHeader(ch.simplePreflightStatus)
+				return
+			}
+		}
+
+		policy, hasPolicy := ch.originPolicies[origin]
+
+		referenceAllowedMethods := ch.allowedMethods
+		if hasPolicy && policy.Methods != nil {
+			referenceAllowedMethods = policy.Methods[:len(policy.Methods):len(policy.Methods)]
+		}
+
+		if ch.allowedMethodsFunc != nil {
+			referenceAllowedMethods = combineAllowedMethods(referenceAllowedMethods, ch.allowedMethodsFunc(r))
+		}
+
 		method := r.Header.Get(corsRequestMethodHeader)
-		if !isMatch(method, ch.allowedMethods) {
-			w.WriteHeader(http.StatusMethodNotAllowed)
+		if !ch.isMethodAllowed(method, referenceAllowedMethods) {
+			ch.rejectPreflight(w, r, http.StatusMethodNotAllowed)
 			return
 		}
 
 		referenceAllowedHeaders := ch.allowedHeaders
+		if hasPolicy && policy.Headers != nil {
+			referenceAllowedHeaders = policy.Headers[:len(policy.Headers):len(policy.Headers)]
+		}
 
 		if ch.allowedHeadersFunc != nil {
 			referenceAllowedHeaders = combineAllowedHeaders(referenceAllowedHeaders, ch.allowedHeadersFunc(r))
 		}
 
-		requestHeaders := strings.Split(r.Header.Get(corsRequestHeadersHeader), ",")
+		requested := requestedHeaders(r)
+		if ch.maxRequestedHeaders > 0 && len(requested) > ch.maxRequestedHeaders {
+			ch.rejectPreflight(w, r, http.StatusBadRequest)
+			return
+		}
+
 		allowedHeaders := []string{}
-		for _, v := range requestHeaders {
-			canonicalHeader := http.CanonicalHeaderKey(strings.TrimSpace(v))
-			if canonicalHeader == "" || isMatch(canonicalHeader, defaultCorsHeaders) {
+		for _, v := range requested {
+			if ch.foldRequestedHeaders {
+				if isMatchFold(v, defaultCorsHeaders) {
+					continue
+				}
+
+				if isMatchFold(v, ch.deniedHeaders) ||
+					(!ch.reflectRequestHeaders && !isHeaderAllowedFold(v, referenceAllowedHeaders)) {
+					ch.rejectPreflight(w, r, http.StatusForbidden)
+					return
+				}
+
+				allowedHeaders = append(allowedHeaders, v)
+				continue
+			}
+
+			canonicalHeader := http.CanonicalHeaderKey(v)
+			if isMatch(canonicalHeader, defaultCorsHeaders) {
 				continue
 			}
 
+			if isMatch(canonicalHeader, ch.deniedHeaders) {
+				ch.rejectPreflight(w, r, http.StatusForbidden)
+				return
+			}
+
 			// TODO - make local
-			if !isMatch(canonicalHeader, referenceAllowedHeaders) {
-				w.WriteHeader(http.StatusForbidden)
+			if !ch.reflectRequestHeaders && !isHeaderAllowed(canonicalHeader, referenceAllowedHeaders) {
+				ch.rejectPreflight(w, r, http.StatusForbidden)
 				return
 			}
 
-			allowedHeaders = append(allowedHeaders, canonicalHeader)
+			if ch.preserveHeaderCase {
+				allowedHeaders = append(allowedHeaders, v)
+			} else {
+				allowedHeaders = append(allowedHeaders, canonicalHeader)
+			}
 		}
 
 		if len(allowedHeaders) > 0 {
-			w.Header().Set(corsAllowHeadersHeader, strings.Join(allowedHeaders, ","))
+			ch.setHeaderList(w, corsAllowHeadersHeader, allowedHeaders)
 		}
 
-		if ch.maxAge > 0 {
-			w.Header().Set(corsMaxAgeHeader, strconv.Itoa(ch.maxAge))
+		maxAge := ch.maxAge
+		if hasPolicy && policy.MaxAge > 0 {
+			maxAge = policy.MaxAge
+		}
+		if maxAge > 0 {
+			w.Header().Set(corsMaxAgeHeader, strconv.Itoa(maxAge))
+		}
+
+		if ch.allowPrivateNetwork && r.Header.Get(corsRequestPrivateNetwork) == "true" && ch.isPrivateNetworkTrusted(r, origin) {
+			w.Header().Set(corsAllowPrivateNetwork, "true")
 		}
 
-		if !isMatch(method, defaultCorsMethods) {
+		if ch.listAllowedMethods && !isMatch(corsMethodMatchAll, referenceAllowedMethods) {
+			methods := combineAllowedMethods(nil, referenceAllowedMethods)
+			sort.Strings(methods)
+			w.Header().Set(corsAllowMethodsHeader, strings.Join(methods, ","))
+		} else if !isMatch(method, defaultCorsMethods) {
 			w.Header().Set(corsAllowMethodsHeader, method)
 		}
 	} else {
-		if len(ch.exposedHeaders) > 0 {
-			w.Header().Set(corsExposeHeadersHeader, strings.Join(ch.exposedHeaders, ","))
+		exposedHeaders := ch.exposedHeaders
+		if ch.exposedHeadersFunc != nil {
+			exposedHeaders = ch.canonicalHeaders(trimHeaders(ch.exposedHeadersFunc(r)))
 		}
-	}
 
-	if ch.allowCredentials {
-		w.Header().Set(corsAllowCredentialsHeader, "true")
+		if len(exposedHeaders) > 0 {
+			ch.setHeaderList(w, corsExposeHeadersHeader, exposedHeaders)
+		}
 	}
 
-	referenceAllowedOrigins := ch.getAllowedOrigins(r)
+	ch.setAllowOrigin(w, r, origin, allowedOrigins)
+
+	if r.Method == corsOptionMethod {
+		ch.writePreflight(w, r)
+		return
+	}
 
-	if len(referenceAllowedOrigins) > 1 {
-		w.Header().Set(corsVaryHeader, corsOriginHeader)
+	if !ch.allowOriginOnSuccess && !ch.exposeOnlyWithBody {
+		ch.next(w, r)
+		return
 	}
 
-	returnOrigin := origin
-	if ch.allowedOriginValidator == nil && len(referenceAllowedOrigins) == 0 {
-		returnOrigin = ch.defaultOrigin
-	} else {
-		for _, o := range referenceAllowedOrigins {
-			// A configuration of * is different than explicitly setting an allowed
-			// origin. Returning arbitrary origin headers in an access control allow
-			// origin header is unsafe and is not required by any use case.
-			if o == corsOriginMatchAll {
-				returnOrigin = "*"
-				break
-			}
+	header := w.Header()
+	ww, done := onWriteHeader(w, func(status int) {
+		if ch.allowOriginOnSuccess && (status < 200 || status > 299) {
+			header.Del(corsAllowOriginHeader)
+			header.Del(corsAllowCredentialsHeader)
+			header.Del(corsExposeHeadersHeader)
+		}
+		if ch.exposeOnlyWithBody && !bodyAllowedForStatus(status) {
+			header.Del(corsExposeHeadersHeader)
+		}
+	})
+	ch.next(ww, r)
+	done()
+}
+
+// setAllowOrigin sets the Access-Control-Allow-Origin header, along with the
+// Vary and Access-Control-Allow-Credentials headers that depend on it, for a
+// request from an allowed origin.
+func (ch *cors) setAllowOrigin(w http.ResponseWriter, r *http.Request, origin string, referenceAllowedOrigins []string) {
+	var vary []string
+	// The allowed origin is reflected whenever it is chosen per request, by
+	// matching against several origins, patterns, validators or a decider.
+	if len(referenceAllowedOrigins) > 1 || hasSubdomainWildcard(referenceAllowedOrigins) || ch.hasOriginPatterns() ||
+		ch.hasOriginValidator() || ch.originDecider != nil ||
+		(ch.ignoreWWWPrefix && len(referenceAllowedOrigins) > 0) || ch.hasDynamicFuncs() {
+		vary = append(vary, corsOriginHeader)
+	}
+	if ch.hasDynamicFuncs() {
+		vary = append(vary, ch.varyHeaders...)
+	}
+	// The Access-Control-Allow-Headers value of a preflight echoes the
+	// requested headers, so caches must key preflights on them.
+	if r.Method == corsOptionMethod {
+		vary = append(vary, corsRequestHeadersHeader)
+		// Whether the origin is allowed depends on the requested method.
+		if len(ch.safeMethodOrigins) > 0 {
+			vary = append(vary, corsRequestMethodHeader)
 		}
 	}
+	addVary(w.Header(), vary)
+
+	returnOrigin := ch.allowOriginValue(origin, referenceAllowedOrigins)
+	safeMethodWildcard := ch.isSafeMethodWildcard(r, origin, referenceAllowedOrigins)
+	if safeMethodWildcard {
+		returnOrigin = corsOriginMatchAll
+	}
 	w.Header().Set(corsAllowOriginHeader, returnOrigin)
 
-	if r.Method == corsOptionMethod {
-		w.WriteHeader(ch.optionStatusCode)
+	if !safeMethodWildcard && ch.credentialsAllowed(r, returnOrigin) {
+		w.Header().Set(corsAllowCredentialsHeader, "true")
+	}
+}
+
+// addVary adds names to the Vary header of h. Values already set by other
+// middleware, such as Accept-Encoding by CompressHandler, are kept so that
+// responses, including 304 Not Modified ones, stay correctly keyed in caches.
+func addVary(h http.Header, names []string) {
+	existing := h[corsVaryHeader]
+	var missing []string
+	for _, name := range names {
+		if !varyContains(existing, name) {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
 		return
 	}
-	ch.h.ServeHTTP(w, r)
+
+	h[corsVaryHeader] = append(existing, strings.Join(missing, ", "))
+}
+
+// varyContains reports whether the Vary header values list name.
+func varyContains(values []string, name string) bool {
+	for _, v := range values {
+		for _, token := range strings.Split(v, ",") {
+			token = strings.TrimSpace(token)
+			if token == "*" || strings.EqualFold(token, name) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// allowOriginValue returns the Access-Control-Allow-Origin value for a
+// request from origin, which has already been allowed, given the allowed
+// origins list for the request:
+//
+//   - with no origin restriction at all (no list, validator, decider or
+//     pattern), defaultOrigin is returned;
+//   - with "*" in the list, "*" is returned, even if the origin was allowed
+//     by other means;
+//   - otherwise origin is reflected, lowercased if LowercaseReflectedOrigin
+//     is set and then passed through the ReflectOriginTransform function.
+func (ch *cors) allowOriginValue(origin string, allowedOrigins []string) string {
+	if ch.usesDefaultOrigin(allowedOrigins) {
+		return ch.defaultOrigin
+	}
+
+	// A configuration of * is different than explicitly setting an allowed
+	// origin. Returning arbitrary origin headers in an access control allow
+	// origin header is unsafe and is not required by any use case.
+	if isMatch(corsOriginMatchAll, allowedOrigins) {
+		return corsOriginMatchAll
+	}
+
+	if ch.lowercaseOrigin {
+		origin = strings.ToLower(origin)
+	}
+	if ch.reflectTransform != nil {
+		origin = ch.reflectTransform(origin)
+	}
+
+	return origin
 }
 
 // CORS provides Cross-Origin Resource Sharing middleware.
@@ -170,29 +489,109 @@ func (ch *cors) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 //      http.ListenAndServe(":8000", handlers.CORS()(r))
 //  }
 //
+// WebSocket opening handshakes are passed to the handler unchanged, since
+// browsers do not apply CORS to them. Handlers accepting WebSocket
+// connections must check the Origin header themselves.
+//
+// Options that fail are logged and otherwise ignored. If StrictCORS is set,
+// CORS instead panics when an option fails or the configuration is unsafe;
+// use NewCORSFromConfig or ValidateCORS to handle such errors.
 func CORS(opts ...CORSOption) func(http.Handler) http.Handler {
 	return func(h http.Handler) http.Handler {
-		ch := parseCORSOptions(opts...)
+		ch, err := newCORS(opts, true)
+		if err != nil {
+			panic(err)
+		}
 		ch.h = h
 		return ch
 	}
 }
 
-func parseCORSOptions(opts ...CORSOption) *cors {
-	ch := &cors{
+func parseCORSOptions(opts ...CORSOption) (*cors, error) {
+	return newCORS(opts, false)
+}
+
+// newCORS applies opts to the default configuration. Unless lenient is set,
+// the first option that fails is returned as an error; otherwise failing
+// options are logged, or returned in strict mode.
+func newCORS(opts []CORSOption, lenient bool) (*cors, error) {
+	ch := defaultCORS()
+
+	var optionErrs []error
+	for _, option := range opts {
+		if err := option(ch); err != nil {
+			if !lenient {
+				return nil, err
+			}
+			optionErrs = append(optionErrs, err)
+		}
+	}
+
+	// Errors are reported once every option is applied, so that the logger
+	// and strict mode are known whatever the order of the options.
+	for _, err := range optionErrs {
+		if ch.strict {
+			return nil, err
+		}
+		ch.log("handlers: ignoring CORS option:", err)
+	}
+
+	ch.exposedHeaders = ch.canonicalHeaders(ch.exposedHeaders)
+	ch.wildcardOnly = ch.isWildcardOnly()
+
+	for _, warning := range ch.configWarnings() {
+		if ch.strict {
+			return nil, errors.New(warning)
+		}
+		ch.log(warning)
+	}
+
+	if ch.requestedMaxAge > ch.maxAge {
+		ch.log(fmt.Sprintf("handlers: CORS MaxAge of %d seconds exceeds the maximum of %d seconds; using %d", ch.requestedMaxAge, corsMaxAgeLimit, ch.maxAge))
+	}
+
+	return ch, nil
+}
+
+// defaultCORS returns the CORS configuration before any option is applied.
+func defaultCORS() *cors {
+	return &cors{
 		allowedMethods:      defaultCorsMethods,
 		allowedHeaders:      defaultCorsHeaders,
 		allowedOrigins:      []string{},
 		optionStatusCode:    defaultCorsOptionStatusCode,
+		maxRequestedHeaders: defaultMaxRequestedHeaders,
+		credentialsActual:   true,
 		allowDefaultOrigins: true,
 		defaultOrigin:       "*",
 	}
+}
 
-	for _, option := range opts {
-		option(ch)
+// configWarnings returns a description of every configuration that is
+// accepted but almost certainly a mistake.
+func (ch *cors) configWarnings() []string {
+	var warnings []string
+
+	usesDefaultOrigin := ch.allowDefaultOrigins && ch.defaultOrigin == corsOriginMatchAll &&
+		len(ch.allowedOrigins) == 0 && ch.allowedOriginsFunc == nil
+	// A validator alone decides which origins are allowed, so it only needs
+	// a warning when the origins it approves are sent credentials.
+	if usesDefaultOrigin && ch.allowCredentials && !ch.hasOriginValidator() {
+		warnings = append(warnings, "handlers: CORS allows credentials while every origin is allowed by default; set AllowedOrigins or call DisallowDefaultOrigins")
 	}
+	if usesDefaultOrigin && ch.allowCredentials && ch.hasOriginValidator() {
+		warnings = append(warnings, "handlers: CORS allows credentials for every origin its validator approves while default origins are allowed; call DisallowDefaultOrigins to rely on the validator alone")
+	}
+
+	return warnings
+}
 
-	return ch
+func (ch *cors) log(v ...interface{}) {
+	if ch.logger != nil {
+		ch.logger.Println(v...)
+	} else {
+		log.Println(v...)
+	}
 }
 
 //
@@ -205,6 +604,12 @@ func parseCORSOptions(opts ...CORSOption) *cors {
 // and Content-Language are always allowed.
 // Content-Type must be explicitly declared if accepting Content-Types other than
 // application/x-www-form-urlencoded, multipart/form-data, or text/plain.
+//
+// An entry ending in "*", such as "X-Trace-*", allows every header starting
+// with that prefix. Prefix entries allow header names the server never
+// anticipated, so keep prefixes specific and never use one that covers
+// security-sensitive headers like Authorization or Cookie. A bare "*" is not
+// a prefix entry and does not allow any header.
 func AllowedHeaders(headers []string) CORSOption {
 	return func(ch *cors) error {
 
@@ -213,6 +618,46 @@ func AllowedHeaders(headers []string) CORSOption {
 	}
 }
 
+// MaxRequestedHeaders limits the number of non-empty entries in the
+// Access-Control-Request-Headers header lines of a preflight. Preflights
+// requesting more headers are rejected with 400 Bad Request, bounding the
+// work done to validate them. The default is 100; a value of 0 or less removes the limit.
+func MaxRequestedHeaders(n int) CORSOption {
+	return func(ch *cors) error {
+		ch.maxRequestedHeaders = n
+		return nil
+	}
+}
+
+// ReflectRequestedHeaders allows any header requested in a preflight and
+// echoes it back in the Access-Control-Allow-Headers header, instead of
+// checking it against AllowedHeaders. Use DeniedHeaders to keep specific
+// headers forbidden.
+func ReflectRequestedHeaders() CORSOption {
+	return func(ch *cors) error {
+		ch.reflectRequestHeaders = true
+		return nil
+	}
+}
+
+// DeniedHeaders sets headers that are always rejected in a preflight with a
+// 403, even when they are allowed by AllowedHeaders, AllowedHeadersFunc or
+// ReflectRequestedHeaders.
+func DeniedHeaders(headers []string) CORSOption {
+	return func(ch *cors) error {
+		ch.deniedHeaders = combineAllowedHeaders(nil, headers)
+		return nil
+	}
+}
+
+// AllowJSONContentType adds Content-Type to the list of allowed headers.
+// Browsers only treat Content-Type as a safelisted request header for
+// application/x-www-form-urlencoded, multipart/form-data and text/plain, so
+// APIs accepting application/json must allow it explicitly.
+func AllowJSONContentType() CORSOption {
+	return AllowedHeaders([]string{"Content-Type"})
+}
+
 // Disallows default origins
 func DisallowDefaultOrigins() CORSOption {
 	return func(ch *cors) error {
@@ -222,6 +667,23 @@ func DisallowDefaultOrigins() CORSOption {
 	}
 }
 
+// requestedHeaders returns the header names listed in the
+// Access-Control-Request-Headers header of r, trimmed of whitespace. Empty
+// entries are dropped, so that a value such as " " or ",,," is treated as
+// requesting no headers.
+func requestedHeaders(r *http.Request) []string {
+	var headers []string
+	for _, value := range r.Header[corsRequestHeadersHeader] {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				headers = append(headers, v)
+			}
+		}
+	}
+
+	return headers
+}
+
 func combineAllowedHeaders(existing, add []string) []string {
 
 	result := existing
@@ -257,27 +719,74 @@ func AllowedHeadersFunc(input func(r *http.Request) []string) CORSOption {
 // Access-Control-Allow-Methods header.
 // This is a replacement operation so you must also
 // pass GET, HEAD, and POST if you wish to support those methods.
+//
+// A method of "*" allows any requested method, which is reflected in the
+// preflight response. Use DeniedMethods to exclude specific methods.
 func AllowedMethods(methods []string) CORSOption {
 	return func(ch *cors) error {
-		ch.allowedMethods = []string{}
-		for _, v := range methods {
+		ch.allowedMethods = combineAllowedMethods([]string{}, methods)
+		return nil
+	}
+}
+
+// DeniedMethods sets methods that are never allowed, even when AllowedMethods
+// contains "*". Preflights requesting them are rejected with 405 Method Not
+// Allowed.
+func DeniedMethods(methods []string) CORSOption {
+	return func(ch *cors) error {
+		ch.deniedMethods = combineAllowedMethods(nil, methods)
+		return nil
+	}
+}
+
+// AllowedMethodsFunc creates a function which appends the allowed methods per
+// CORS request. The methods it returns are added to those set by
+// AllowedMethods.
+func AllowedMethodsFunc(input func(r *http.Request) []string) CORSOption {
+	return func(ch *cors) error {
+		ch.allowedMethodsFunc = input
+		return nil
+	}
+}
+
+// ListAllowedMethods makes preflight responses list every allowed method in
+// the Access-Control-Allow-Methods header, de-duplicated and sorted, instead
+// of only echoing the requested method. The stable value keeps preflight
+// responses cacheable.
+func ListAllowedMethods() CORSOption {
+	return func(ch *cors) error {
+		ch.listAllowedMethods = true
+		return nil
+	}
+}
+
+// combineAllowedMethods returns a new slice holding the methods of existing
+// followed by the normalized methods of add that are not already present.
+func combineAllowedMethods(existing, add []string) []string {
+	result := make([]string, 0, len(existing)+len(add))
+	for _, list := range [][]string{existing, add} {
+		for _, v := range list {
 			normalizedMethod := strings.ToUpper(strings.TrimSpace(v))
 			if normalizedMethod == "" {
 				continue
 			}
 
-			if !isMatch(normalizedMethod, ch.allowedMethods) {
-				ch.allowedMethods = append(ch.allowedMethods, normalizedMethod)
+			if !isMatch(normalizedMethod, result) {
+				result = append(result, normalizedMethod)
 			}
 		}
-
-		return nil
 	}
+
+	return result
 }
 
 // AllowedOrigins sets the allowed origins for CORS requests, as used in the
 // 'Allow-Access-Control-Origin' HTTP header.
 // Note: Passing in a []string{"*"} will allow any domain.
+//
+// An entry of the form "https://*.example.com" (or "*.example.com" to accept
+// any scheme) allows every subdomain of example.com, but not example.com
+// itself. Combine it with DeniedOrigins to exclude specific subdomains.
 func AllowedOrigins(origins []string) CORSOption {
 	return func(ch *cors) error {
 		ch.allowedOrigins = filterAllowedOrigins(origins)
@@ -285,14 +794,80 @@ func AllowedOrigins(origins []string) CORSOption {
 	}
 }
 
+// SafeMethodOrigins allows origins, in addition to those allowed by the other
+// origin options, for GET and HEAD requests only. This lets resources be read
+// from a broad set of origins, or from any origin with []string{"*"}, while
+// requests that change state are still restricted to the exact allowlist.
+// For preflights the method in Access-Control-Request-Method is considered.
+//
+// An origin allowed only by a "*" entry is answered with
+// Access-Control-Allow-Origin: * and never with credentials, even when
+// AllowCredentials is set.
+//
+// Example:
+//
+//  handlers.CORS(
+//  	handlers.AllowedOrigins([]string{"https://app.example.com"}),
+//  	handlers.SafeMethodOrigins([]string{"*"}),
+//  )
+func SafeMethodOrigins(origins []string) CORSOption {
+	return func(ch *cors) error {
+		ch.safeMethodOrigins = filterAllowedOrigins(origins)
+		return nil
+	}
+}
+
 // AllowedOrigins sets the allowed origins for CORS requests based on the
 // result of a function, as used in the
 // 'Allow-Access-Control-Origin' HTTP header.
 // Note: Passing in a []string{"*"} will allow any domain.
+//
+// The function is called at most once per request, while the request is
+// being served. If it performs I/O it should honour req.Context(), or be
+// wrapped with AllowedOriginsLoader to bound its latency.
 func AllowedOriginsFunc(input func(req *http.Request) []string) CORSOption {
 	return func(ch *cors) error {
-		ch.allowedOriginsFunc = func(req *http.Request) []string {
-			return filterAllowedOrigins(input(req))
+		ch.allowedOriginsFunc = func(req *http.Request) ([]string, error) {
+			return filterAllowedOrigins(input(req)), nil
+		}
+		return nil
+	}
+}
+
+// AllowedOriginsLoader sets the allowed origins for CORS requests based on
+// the result of loader, which is given the request with a context that
+// expires after timeout. If loader returns an error or does not finish in
+// time, the origin is denied.
+//
+// Unlike AllowedOriginsFunc, an empty result from loader denies all origins
+// instead of falling back to the default origins.
+func AllowedOriginsLoader(timeout time.Duration, loader func(req *http.Request) ([]string, error)) CORSOption {
+	type result struct {
+		origins []string
+		err     error
+	}
+
+	return func(ch *cors) error {
+		ch.allowDefaultOrigins = false
+		ch.allowedOriginsFunc = func(req *http.Request) ([]string, error) {
+			ctx, cancel := context.WithTimeout(req.Context(), timeout)
+			defer cancel()
+
+			done := make(chan result, 1)
+			go func() {
+				origins, err := loader(req.WithContext(ctx))
+				done <- result{origins, err}
+			}()
+
+			select {
+			case res := <-done:
+				if res.err != nil {
+					return nil, res.err
+				}
+				return filterAllowedOrigins(res.origins), nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
 		}
 		return nil
 	}
@@ -317,90 +892,958 @@ func AllowedOriginValidator(fn OriginValidator) CORSOption {
 	}
 }
 
-// OptionStatusCode sets a custom status code on the OPTIONS requests.
-// Default behaviour sets it to 200 to reflect best practices. This is option is not mandatory
-// and can be used if you need a custom status code (i.e 204).
-//
-// More informations on the spec:
-// https://fetch.spec.whatwg.org/#cors-preflight-fetch
-func OptionStatusCode(code int) CORSOption {
+// AllowedOriginValidators adds validators that are consulted together with
+// the one set by AllowedOriginValidator: an origin is allowed if any of them
+// returns true. This lets independently maintained policies be combined
+// without merging them into a single function. As with
+// AllowedOriginValidator, the AllowedOrigins list is not consulted once a
+// validator is set.
+func AllowedOriginValidators(fns ...OriginValidator) CORSOption {
 	return func(ch *cors) error {
-		ch.optionStatusCode = code
+		ch.originValidators = append(ch.originValidators, fns...)
 		return nil
 	}
 }
 
-// ExposedHeaders can be used to specify headers that are available
-// and will not be stripped out by the user-agent.
-func ExposedHeaders(headers []string) CORSOption {
+// AllowedOriginSuffixes allows every https origin whose host ends with one of
+// the given domain suffixes, such as ".example.com". Matching respects label
+// boundaries: ".example.com" matches "example.com" and "api.example.com" but
+// not "notexample.com". The matched origin is reflected in the response.
+//
+// Suffixes are consulted in addition to AllowedOrigins and
+// AllowedOriginValidator. Use AllowHTTPOriginSuffixes to also match http
+// origins.
+func AllowedOriginSuffixes(suffixes []string) CORSOption {
 	return func(ch *cors) error {
-		ch.exposedHeaders = []string{}
-		for _, v := range headers {
-			normalizedHeader := http.CanonicalHeaderKey(strings.TrimSpace(v))
-			if normalizedHeader == "" {
+		ch.allowedOriginSuffixes = []string{}
+		for _, v := range suffixes {
+			suffix := strings.ToLower(strings.TrimSpace(v))
+			if suffix == "" || suffix == "." {
 				continue
 			}
-
-			if !isMatch(normalizedHeader, ch.exposedHeaders) {
-				ch.exposedHeaders = append(ch.exposedHeaders, normalizedHeader)
+			if !strings.HasPrefix(suffix, ".") {
+				suffix = "." + suffix
 			}
+			ch.allowedOriginSuffixes = append(ch.allowedOriginSuffixes, suffix)
 		}
-
 		return nil
 	}
 }
 
-// MaxAge determines the maximum age (in seconds) between preflight requests. A
-// maximum of 10 minutes is allowed. An age above this value will default to 10
-// minutes.
-func MaxAge(age int) CORSOption {
+// AllowHTTPOriginSuffixes makes AllowedOriginSuffixes match http origins as
+// well as https ones.
+func AllowHTTPOriginSuffixes() CORSOption {
 	return func(ch *cors) error {
-		// Maximum of 10 minutes.
-		if age > 600 {
-			age = 600
-		}
-
-		ch.maxAge = age
+		ch.allowHTTPSuffixes = true
 		return nil
 	}
 }
 
-// IgnoreOptions causes the CORS middleware to ignore OPTIONS requests, instead
-// passing them through to the next handler. This is useful when your application
-// or framework has a pre-existing mechanism for responding to OPTIONS requests.
-func IgnoreOptions() CORSOption {
+// AllowedOriginsGlob allows every origin matching one of the given
+// shell-style glob patterns, such as "https://*.dev.*.example.com". The
+// scheme of a pattern is compared literally and the host is matched with
+// path.Match semantics, where dots take the place of slashes: "*" and "?"
+// never match a ".", so each "*" stands for part or all of a single DNS
+// label. A port must be matched explicitly, for example
+// "http://*.example.com:*". The matched origin is reflected in the response.
+//
+// Origins are allowed if they match a glob, a domain suffix, an IP range, the
+// AllowedOrigins list or an origin validator; DeniedOrigins and
+// AllowedOriginDecider take precedence over all of them. An error is
+// returned if a pattern lacks a scheme or is malformed.
+func AllowedOriginsGlob(patterns []string) CORSOption {
 	return func(ch *cors) error {
-		ch.ignoreOptions = true
+		ch.allowedOriginGlobs = []string{}
+		for _, v := range patterns {
+			pattern := strings.ToLower(strings.TrimSpace(v))
+			i := strings.Index(pattern, "://")
+			if i <= 0 || strings.ContainsAny(pattern[:i], "*?[\\") {
+				return fmt.Errorf("handlers: invalid CORS origin glob %q", v)
+			}
+			if _, err := path.Match(globHost(pattern[i+3:]), ""); err != nil {
+				return fmt.Errorf("handlers: invalid CORS origin glob %q: %v", v, err)
+			}
+			ch.allowedOriginGlobs = append(ch.allowedOriginGlobs, pattern)
+		}
 		return nil
 	}
 }
 
-// AllowCredentials can be used to specify that the user agent may pass
-// authentication details along with the request.
-func AllowCredentials() CORSOption {
+// OriginPolicy holds the CORS settings applied to requests from one origin
+// registered with OriginPolicies.
+type OriginPolicy struct {
+	// Methods replaces the allowed methods, as with AllowedMethods, if it is
+	// not nil.
+	Methods []string
+	// Headers replaces the headers added by AllowedHeaders, if it is not
+	// nil. The safelisted headers are always allowed.
+	Headers []string
+	// MaxAge replaces the value set by MaxAge if it is positive. It is
+	// capped at 600 seconds.
+	MaxAge int
+	// AllowCredentials decides whether credentials are allowed, in place of
+	// AllowCredentials and AllowCredentialsFunc.
+	AllowCredentials bool
+}
+
+// OriginPolicies allows each origin in policies and applies its policy to
+// requests from it, keeping per-origin tuning in one place. Origins are
+// matched exactly. Requests from other allowed origins use the global
+// configuration; DeniedOrigins and AllowedOriginDecider still take precedence.
+//
+// Example:
+//
+//  handlers.CORS(handlers.OriginPolicies(map[string]handlers.OriginPolicy{
+//      "https://app.example.com":   {Methods: []string{"GET", "PUT"}, MaxAge: 600, AllowCredentials: true},
+//      "https://admin.example.com": {Methods: []string{"GET", "DELETE"}, MaxAge: 60},
+//  }))
+func OriginPolicies(policies map[string]OriginPolicy) CORSOption {
 	return func(ch *cors) error {
-		ch.allowCredentials = true
+		ch.originPolicies = make(map[string]OriginPolicy, len(policies))
+		for origin, policy := range policies {
+			if policy.Methods != nil {
+				policy.Methods = combineAllowedMethods([]string{}, policy.Methods)
+			}
+			if policy.Headers != nil {
+				policy.Headers = combineAllowedHeaders(append([]string(nil), defaultCorsHeaders...), policy.Headers)
+			}
+			if policy.MaxAge > corsMaxAgeLimit {
+				policy.MaxAge = corsMaxAgeLimit
+			}
+			ch.originPolicies[strings.TrimSpace(origin)] = policy
+		}
 		return nil
 	}
 }
 
-func (ch *cors) isOriginAllowed(r *http.Request, origin string) bool {
-	if origin == "" {
-		return false
+// IgnoreWWWPrefix treats a leading "www." in the host as optional when
+// comparing origins with the AllowedOrigins list, so that
+// "https://example.com" also matches "https://www.example.com" and vice
+// versa. The incoming origin is reflected unchanged.
+func IgnoreWWWPrefix() CORSOption {
+	return func(ch *cors) error {
+		ch.ignoreWWWPrefix = true
+		return nil
 	}
+}
 
-	allowedOrigins := ch.getAllowedOrigins(r)
-
-	if ch.allowedOriginValidator != nil {
-		return ch.allowedOriginValidator(origin)
+// AllowedOriginCIDRs allows http and https origins whose host is an IP
+// address literal within one of the given CIDR ranges, such as
+// "10.0.0.0/8", on any port. The matched origin is reflected in the
+// response. An error is returned if a range cannot be parsed.
+func AllowedOriginCIDRs(cidrs []string) CORSOption {
+	return func(ch *cors) error {
+		ch.allowedOriginNets = []*net.IPNet{}
+		for _, v := range cidrs {
+			_, n, err := net.ParseCIDR(strings.TrimSpace(v))
+			if err != nil {
+				return err
+			}
+			ch.allowedOriginNets = append(ch.allowedOriginNets, n)
+		}
+		return nil
 	}
+}
 
-	if len(allowedOrigins) == 0 {
+// AllowLocalhostOrigins allows http and https origins on localhost,
+// 127.0.0.1 or [::1], on any port. It is meant for development; enable it
+// only outside production, for example:
+//
+//  if devMode {
+//      opts = append(opts, handlers.AllowLocalhostOrigins())
+//  }
+func AllowLocalhostOrigins() CORSOption {
+	return func(ch *cors) error {
+		ch.allowLocalhost = true
+		return nil
+	}
+}
+
+// AllowPrivateNetwork answers Private Network Access preflights, which
+// browsers send before a public website makes a request to a server on a
+// private network, by sending Access-Control-Allow-Private-Network.
+//
+// By default every allowed origin is granted access. If trusted functions
+// are passed, access is only granted to allowed origins for which at least
+// one of them returns true.
+func AllowPrivateNetwork(trusted ...func(r *http.Request, origin string) bool) CORSOption {
+	return func(ch *cors) error {
+		ch.allowPrivateNetwork = true
+		ch.privateNetworkTrusted = trusted
+		return nil
+	}
+}
+
+// DeniedOrigins sets origins that are always rejected, even when they match
+// AllowedOrigins, a subdomain wildcard or the AllowedOriginValidator. Entries
+// may use the same subdomain wildcard form as AllowedOrigins.
+func DeniedOrigins(origins []string) CORSOption {
+	return func(ch *cors) error {
+		ch.deniedOrigins = origins
+		return nil
+	}
+}
+
+// AllowNullOrigin allows requests with the opaque origin "null", sent by
+// sandboxed iframes and pages loaded from file: URLs, and reflects it in the
+// Access-Control-Allow-Origin header. Any page can produce a "null" origin, so
+// only enable this for resources that are safe to share with everyone.
+func AllowNullOrigin() CORSOption {
+	return func(ch *cors) error {
+		ch.allowNullOrigin = true
+		return nil
+	}
+}
+
+// ForbidNullReflection denies the "null" origin regardless of any other
+// configuration, including AllowNullOrigin, the allowed origins list and
+// validators, so "null" is never reflected.
+func ForbidNullReflection() CORSOption {
+	return func(ch *cors) error {
+		ch.forbidNullOrigin = true
+		return nil
+	}
+}
+
+// AllowedOriginDecider sets a function that decides whether an origin is
+// allowed. It takes precedence over AllowedOriginValidator and the allowed
+// origins list. When it denies an origin and returns a non-nil handler, that
+// handler writes the response and the wrapped handler is not called.
+func AllowedOriginDecider(fn OriginDecider) CORSOption {
+	return func(ch *cors) error {
+		ch.originDecider = fn
+		return nil
+	}
+}
+
+// OptionStatusCode sets a custom status code on the OPTIONS requests.
+// Default behaviour sets it to 200 to reflect best practices. This is option is not mandatory
+// and can be used if you need a custom status code (i.e 204).
+//
+// More informations on the spec:
+// https://fetch.spec.whatwg.org/#cors-preflight-fetch
+func OptionStatusCode(code int) CORSOption {
+	return func(ch *cors) error {
+		ch.optionStatusCode = code
+		return nil
+	}
+}
+
+// OptionStatusCodeFunc sets a function that chooses the status code of
+// successful preflight responses per request, for example by origin or
+// User-Agent for clients that only accept 200 or 204. If the function
+// returns a status that is not 2xx, OptionStatusCode applies.
+func OptionStatusCodeFunc(fn func(r *http.Request) int) CORSOption {
+	return func(ch *cors) error {
+		ch.optionStatusCodeFunc = fn
+		return nil
+	}
+}
+
+// SimplePreflightStatus answers preflights that were not needed, because they
+// are for a GET, HEAD or POST request using only safelisted headers, with
+// code and only the Access-Control-Allow-Origin and related headers. This
+// helps to spot clients that send preflights unnecessarily.
+func SimplePreflightStatus(code int) CORSOption {
+	return func(ch *cors) error {
+		ch.simplePreflightStatus = code
+		return nil
+	}
+}
+
+// PassThroughSimplePreflights passes preflights that were not needed, as
+// described for SimplePreflightStatus, to the next handler.
+func PassThroughSimplePreflights() CORSOption {
+	return func(ch *cors) error {
+		ch.passSimplePreflight = true
+		return nil
+	}
+}
+
+// PreflightBody sets the body written with successful preflight responses.
+// An empty body is sent with an explicit Content-Length of 0, which some
+// intermediaries require. No body is written when the preflight status does
+// not permit one, such as 204.
+func PreflightBody(body string) CORSOption {
+	return func(ch *cors) error {
+		ch.preflightBody = &body
+		return nil
+	}
+}
+
+// CORSAudit sets a function that receives a copy of the Access-Control-* and
+// Vary headers of every response, just before the headers are written. Unlike
+// an OriginDecider it sees the values actually emitted, which makes it
+// suitable for security audit logs. Requests whose handler panics before
+// writing a response are audited as the panic unwinds.
+func CORSAudit(fn CORSAuditFunc) CORSOption {
+	return func(ch *cors) error {
+		ch.audit = fn
+		return nil
+	}
+}
+
+// ExposedHeaders can be used to specify headers that are available
+// and will not be stripped out by the user-agent.
+func ExposedHeaders(headers []string) CORSOption {
+	return func(ch *cors) error {
+		// Canonicalization is applied once all options have been parsed, so
+		// that PreserveHeaderCase can be passed in any order.
+		ch.exposedHeaders = trimHeaders(headers)
+		return nil
+	}
+}
+
+// ExposedHeadersFunc sets a function returning the headers to expose for a
+// request, for example depending on its origin. When set, it takes precedence
+// over ExposedHeaders. The returned headers are normalized in the same way.
+func ExposedHeadersFunc(fn func(r *http.Request) []string) CORSOption {
+	return func(ch *cors) error {
+		ch.exposedHeadersFunc = fn
+		return nil
+	}
+}
+
+// trimHeaders returns headers with surrounding whitespace, empty entries and
+// case-insensitive duplicates removed.
+func trimHeaders(headers []string) []string {
+	trimmed := []string{}
+	for _, v := range headers {
+		trimmedHeader := strings.TrimSpace(v)
+		if trimmedHeader == "" {
+			continue
+		}
+
+		if !isMatchFold(trimmedHeader, trimmed) {
+			trimmed = append(trimmed, trimmedHeader)
+		}
+	}
+
+	return trimmed
+}
+
+// canonicalHeaders canonicalizes headers in place, unless PreserveHeaderCase
+// is set.
+func (ch *cors) canonicalHeaders(headers []string) []string {
+	if !ch.preserveHeaderCase {
+		for i, v := range headers {
+			headers[i] = http.CanonicalHeaderKey(v)
+		}
+	}
+
+	return headers
+}
+
+// FoldRequestedHeaders compares the headers requested by a preflight with
+// AllowedHeaders and DeniedHeaders case-insensitively, instead of
+// canonicalizing them with http.CanonicalHeaderKey first. The
+// Access-Control-Allow-Headers value then echoes the header names exactly as
+// the client sent them, so that names such as "Sec-WebSocket-Protocol" are
+// not rewritten to "Sec-Websocket-Protocol".
+func FoldRequestedHeaders() CORSOption {
+	return func(ch *cors) error {
+		ch.foldRequestedHeaders = true
+		return nil
+	}
+}
+
+// PreserveHeaderCase disables canonicalization of header names emitted by the
+// middleware. Exposed headers are sent with the exact casing passed to
+// ExposedHeaders, and the Access-Control-Allow-Headers value of a preflight
+// echoes the casing sent by the client. Matching against AllowedHeaders is
+// still case-insensitive.
+func PreserveHeaderCase() CORSOption {
+	return func(ch *cors) error {
+		ch.preserveHeaderCase = true
+		return nil
+	}
+}
+
+// VaryHeaders adds request headers to the Vary header of responses when
+// AllowedOriginsFunc, AllowedHeadersFunc, AllowedMethodsFunc,
+// ExposedHeadersFunc or AllowCredentialsFunc is used, so that shared caches
+// key responses on the inputs those functions read, such as a tenant header.
+// Origin is always included in that case.
+func VaryHeaders(headers ...string) CORSOption {
+	return func(ch *cors) error {
+		for _, v := range headers {
+			if header := http.CanonicalHeaderKey(strings.TrimSpace(v)); header != "" && !isMatch(header, ch.varyHeaders) {
+				ch.varyHeaders = append(ch.varyHeaders, header)
+			}
+		}
+		return nil
+	}
+}
+
+// SplitHeaderValues emits the Access-Control-Allow-Headers and
+// Access-Control-Expose-Headers headers with one header line per value,
+// instead of a single comma separated line, for intermediaries that handle
+// long header values poorly.
+func SplitHeaderValues() CORSOption {
+	return func(ch *cors) error {
+		ch.splitHeaderValues = true
+		return nil
+	}
+}
+
+// LowercaseReflectedOrigin lowercases the scheme and host of an origin
+// before reflecting it in the Access-Control-Allow-Origin header, for clients
+// that compare it case-sensitively with an Origin an intermediary has
+// altered. By default the origin is reflected exactly as received.
+func LowercaseReflectedOrigin() CORSOption {
+	return func(ch *cors) error {
+		ch.lowercaseOrigin = true
+		return nil
+	}
+}
+
+// ReflectOriginTransform sets a function that rewrites an allowed origin
+// before it is reflected in the Access-Control-Allow-Origin header, for
+// example to strip a port added by a normalizing proxy. It is applied after
+// the origin has been allowed and does not affect that decision, nor
+// responses that send "*".
+func ReflectOriginTransform(fn func(origin string) string) CORSOption {
+	return func(ch *cors) error {
+		ch.reflectTransform = fn
+		return nil
+	}
+}
+
+// MaxAge determines the maximum age (in seconds) between preflight requests. A
+// maximum of 10 minutes is allowed. An age above this value will default to 10
+// minutes, and a warning is logged when the middleware is constructed.
+func MaxAge(age int) CORSOption {
+	return func(ch *cors) error {
+		ch.requestedMaxAge = age
+
+		// Maximum of 10 minutes.
+		if age > corsMaxAgeLimit {
+			age = corsMaxAgeLimit
+		}
+
+		ch.maxAge = age
+		return nil
+	}
+}
+
+// IgnoreOptions causes the CORS middleware to ignore OPTIONS requests, instead
+// passing them through to the next handler. This is useful when your application
+// or framework has a pre-existing mechanism for responding to OPTIONS requests.
+func IgnoreOptions() CORSOption {
+	return func(ch *cors) error {
+		ch.ignoreOptions = true
+		return nil
+	}
+}
+
+// DisallowedOriginEmptyHeader causes the middleware to send an empty
+// Access-Control-Allow-Origin header when a request carries an Origin that is
+// not allowed. By default the header is omitted entirely. Either way the
+// browser will block the response; this option only changes how some client
+// libraries report the failure.
+func DisallowedOriginEmptyHeader() CORSOption {
+	return func(ch *cors) error {
+		ch.emptyOriginOnDisallow = true
+		return nil
+	}
+}
+
+// AllowCredentials can be used to specify that the user agent may pass
+// authentication details along with the request.
+func AllowCredentials() CORSOption {
+	return func(ch *cors) error {
+		ch.allowCredentials = true
+		return nil
+	}
+}
+
+// AllowCredentialsFunc sets a function deciding per request whether the user
+// agent may pass authentication details, for example depending on the
+// tenant. It takes precedence over AllowCredentials. Credentials are never
+// allowed by fn alongside a wildcard Access-Control-Allow-Origin, so
+// configure explicit origins when using it.
+func AllowCredentialsFunc(fn func(r *http.Request) bool) CORSOption {
+	return func(ch *cors) error {
+		ch.allowCredentialsFunc = fn
+		return nil
+	}
+}
+
+// CORSLogger sets the logger used to report configuration warnings. The
+// standard logger is used by default.
+func CORSLogger(logger RecoveryHandlerLogger) CORSOption {
+	return func(ch *cors) error {
+		ch.logger = logger
+		return nil
+	}
+}
+
+// StrictCORS turns configuration warnings and failing options into errors,
+// causing CORS to panic when the middleware is constructed with an unsafe or
+// invalid configuration.
+func StrictCORS() CORSOption {
+	return func(ch *cors) error {
+		ch.strict = true
+		return nil
+	}
+}
+
+// AllowCredentialsOnPreflightOnly works like AllowCredentials but only sends
+// the Access-Control-Allow-Credentials header in preflight responses.
+// Browsers reject credentialed actual requests whose response lacks the
+// header, so only use this when the actual requests are not credentialed.
+func AllowCredentialsOnPreflightOnly() CORSOption {
+	return func(ch *cors) error {
+		ch.allowCredentials = true
+		ch.credentialsActual = false
+		return nil
+	}
+}
+
+// PreflightCredentialsFunc limits the preflight responses that carry
+// Access-Control-Allow-Credentials to those for which fn returns true. The
+// middleware cannot tell whether the actual request will be credentialed, so
+// fn should approximate it from the preflight, for example by path. Actual
+// requests are not affected.
+func PreflightCredentialsFunc(fn func(r *http.Request) bool) CORSOption {
+	return func(ch *cors) error {
+		ch.preflightCredentials = fn
+		return nil
+	}
+}
+
+// ValidateFetchMetadata rejects requests, including preflights, whose
+// Sec-Fetch-Site header is not one of sites with 403 Forbidden, as defense
+// in depth alongside the Origin check. For example, passing "same-origin"
+// and "same-site" rejects cross-site requests to a credentialed endpoint even
+// if the Origin header has been forged or stripped. allowMissing decides
+// whether requests without the header, such as those from older browsers or
+// non-browser clients, are served.
+func ValidateFetchMetadata(sites []string, allowMissing bool) CORSOption {
+	return func(ch *cors) error {
+		ch.fetchSites = []string{}
+		for _, v := range sites {
+			ch.fetchSites = append(ch.fetchSites, strings.ToLower(strings.TrimSpace(v)))
+		}
+		ch.allowMissingFetchSite = allowMissing
+		return nil
+	}
+}
+
+// AllowOriginOnSuccess withholds the Access-Control-Allow-Origin,
+// Access-Control-Allow-Credentials and Access-Control-Expose-Headers headers
+// from actual responses unless the handler responds with a 2xx status, so
+// that error responses such as 401 or 403 are not readable cross-origin. The
+// decision is made when the handler writes the response headers. Preflight
+// responses are not affected.
+func AllowOriginOnSuccess() CORSOption {
+	return func(ch *cors) error {
+		ch.allowOriginOnSuccess = true
+		return nil
+	}
+}
+
+// HealthCheckPreflights answers OPTIONS requests without an Origin header
+// that come from one of userAgents, matched as case-insensitive substrings of
+// the User-Agent header, or are for one of paths, with the preflight status
+// code and the first allowed origin. This lets synthetic checks and
+// monitoring tools that probe with OPTIONS succeed. Browsers always send an
+// Origin with preflights, so their requests are not affected.
+func HealthCheckPreflights(userAgents, paths []string) CORSOption {
+	return func(ch *cors) error {
+		ch.healthCheckUserAgents = []string{}
+		for _, v := range userAgents {
+			if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+				ch.healthCheckUserAgents = append(ch.healthCheckUserAgents, v)
+			}
+		}
+		ch.healthCheckPaths = paths
+		return nil
+	}
+}
+
+// ExposeHeadersOnlyWithBody omits the Access-Control-Expose-Headers header
+// from responses whose status does not allow a body, such as 204 No Content
+// and 304 Not Modified, where it adds weight to no effect. Responses to HEAD
+// requests keep it, since their headers are all a client can read. By
+// default the header is sent on every actual response.
+func ExposeHeadersOnlyWithBody() CORSOption {
+	return func(ch *cors) error {
+		ch.exposeOnlyWithBody = true
+		return nil
+	}
+}
+
+// HeadersOnly makes the middleware purely additive: it only ever adds CORS
+// headers and never rejects a request. Requests from disallowed origins are
+// passed through without CORS headers, and invalid preflights are answered
+// with the usual preflight status code but without CORS headers instead of a
+// 4xx status. Denial handlers returned by an OriginDecider are not called.
+func HeadersOnly() CORSOption {
+	return func(ch *cors) error {
+		ch.headersOnly = true
+		return nil
+	}
+}
+
+// EnforceAllowOriginHeader makes the middleware the only source of the
+// Access-Control-Allow-Origin header. Any value set by the wrapped handler is
+// replaced with the one chosen by the middleware; when the middleware sets
+// none, a handler value listing several origins is removed, since browsers
+// reject it anyway.
+func EnforceAllowOriginHeader() CORSOption {
+	return func(ch *cors) error {
+		ch.enforceAllowOrigin = true
+		return nil
+	}
+}
+
+// next calls the wrapped handler, enforcing the Access-Control-Allow-Origin
+// header if configured to do so.
+func (ch *cors) next(w http.ResponseWriter, r *http.Request) {
+	if !ch.enforceAllowOrigin {
+		ch.h.ServeHTTP(w, r)
+		return
+	}
+
+	allowOrigin, set := w.Header()[corsAllowOriginHeader]
+	allowOrigin = append([]string(nil), allowOrigin...)
+
+	ww, done := onWriteHeader(w, func(int) {
+		h := w.Header()
+		if set {
+			h[corsAllowOriginHeader] = allowOrigin
+			return
+		}
+
+		if values := h[corsAllowOriginHeader]; len(values) > 1 || (len(values) == 1 && strings.Contains(values[0], ",")) {
+			h.Del(corsAllowOriginHeader)
+		}
+	})
+
+	ch.h.ServeHTTP(ww, r)
+	done()
+}
+
+// setHeaderList sets the response header name to values, either joined or
+// as separate lines.
+func (ch *cors) setHeaderList(w http.ResponseWriter, name string, values []string) {
+	if !ch.splitHeaderValues {
+		w.Header().Set(name, strings.Join(values, ","))
+		return
+	}
+
+	w.Header().Del(name)
+	for _, v := range values {
+		w.Header().Add(name, v)
+	}
+}
+
+// isWebSocketUpgrade reports whether r is a WebSocket opening handshake.
+func isWebSocketUpgrade(r *http.Request) bool {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+
+	for _, v := range r.Header["Connection"] {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// corsResponseHeaders returns a copy of the CORS related headers in h.
+func corsResponseHeaders(h http.Header) http.Header {
+	headers := make(http.Header)
+	for k, v := range h {
+		if strings.HasPrefix(k, "Access-Control-") || k == corsVaryHeader {
+			headers[k] = append([]string(nil), v...)
+		}
+	}
+
+	return headers
+}
+
+// rejectPreflight fails a preflight request with status, unless the
+// middleware is in headers-only mode.
+func (ch *cors) rejectPreflight(w http.ResponseWriter, r *http.Request, status int) {
+	if ch.headersOnly {
+		status = ch.preflightStatus(r)
+	}
+
+	w.WriteHeader(status)
+}
+
+// hasDynamicFuncs reports whether the response depends on request inputs
+// through functions the middleware cannot inspect.
+func (ch *cors) hasDynamicFuncs() bool {
+	return ch.allowedOriginsFunc != nil || ch.allowedHeadersFunc != nil || ch.allowedMethodsFunc != nil ||
+		ch.exposedHeadersFunc != nil || ch.allowCredentialsFunc != nil || ch.optionStatusCodeFunc != nil
+}
+
+// isFetchSiteAllowed reports whether the Sec-Fetch-Site header of r is
+// consistent with the ValidateFetchMetadata configuration.
+func (ch *cors) isFetchSiteAllowed(r *http.Request) bool {
+	site := r.Header.Get(corsFetchSiteHeader)
+	if site == "" {
+		return ch.allowMissingFetchSite
+	}
+
+	return isMatch(strings.ToLower(site), ch.fetchSites)
+}
+
+// isHealthCheck reports whether the Origin-less OPTIONS request r matches
+// the HealthCheckPreflights configuration.
+func (ch *cors) isHealthCheck(r *http.Request) bool {
+	if isMatch(r.URL.Path, ch.healthCheckPaths) {
+		return true
+	}
+
+	userAgent := strings.ToLower(r.UserAgent())
+	for _, v := range ch.healthCheckUserAgents {
+		if strings.Contains(userAgent, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeHealthCheck answers the health check r with the first allowed origin.
+func (ch *cors) writeHealthCheck(w http.ResponseWriter, r *http.Request) {
+	allowedOrigins, err := ch.getAllowedOrigins(r)
+	if err == nil && len(allowedOrigins) > 0 {
+		w.Header().Set(corsAllowOriginHeader, allowedOrigins[0])
+	} else if err == nil && ch.usesDefaultOrigin(allowedOrigins) && ch.allowDefaultOrigins {
+		w.Header().Set(corsAllowOriginHeader, ch.defaultOrigin)
+	}
+
+	ch.writePreflight(w, r)
+}
+
+// isWildcardOnly reports whether every origin other than "*" itself is
+// allowed and answered with Access-Control-Allow-Origin: *, without
+// credentials, so that the outcome of the origin checks is known in advance.
+func (ch *cors) isWildcardOnly() bool {
+	wildcard := len(ch.allowedOrigins) == 1 && ch.allowedOrigins[0] == corsOriginMatchAll
+	if len(ch.allowedOrigins) == 0 {
+		wildcard = ch.allowDefaultOrigins
+	}
+
+	return wildcard && ch.defaultOrigin == corsOriginMatchAll &&
+		!ch.allowCredentials &&
+		!ch.forbidNullOrigin &&
+		len(ch.deniedOrigins) == 0 &&
+		ch.originDecider == nil &&
+		!ch.hasOriginValidator() &&
+		!ch.hasOriginPatterns() &&
+		!ch.hasDynamicFuncs() &&
+		ch.audit == nil
+}
+
+// isMethodAllowed reports whether a preflight may request method.
+func (ch *cors) isMethodAllowed(method string, allowedMethods []string) bool {
+	if isMatch(method, ch.deniedMethods) {
+		return false
+	}
+
+	return isMatch(method, allowedMethods) || isMatch(corsMethodMatchAll, allowedMethods)
+}
+
+// isPrivateNetworkTrusted reports whether the allowed origin may access the
+// private network.
+func (ch *cors) isPrivateNetworkTrusted(r *http.Request, origin string) bool {
+	if len(ch.privateNetworkTrusted) == 0 {
+		return true
+	}
+
+	for _, fn := range ch.privateNetworkTrusted {
+		if fn(r, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isUnnecessaryPreflight reports whether the preflight r is for a request
+// that a browser would have sent without one.
+func (ch *cors) isUnnecessaryPreflight(r *http.Request) bool {
+	if !isMatch(r.Header.Get(corsRequestMethodHeader), defaultCorsMethods) {
+		return false
+	}
+
+	for _, v := range requestedHeaders(r) {
+		if !isMatch(http.CanonicalHeaderKey(v), defaultCorsHeaders) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// preflightStatus returns the status code of a successful preflight response
+// to r. A code returned by the OptionStatusCodeFunc that is not 2xx is
+// ignored in favour of OptionStatusCode.
+func (ch *cors) preflightStatus(r *http.Request) int {
+	if ch.optionStatusCodeFunc != nil {
+		if code := ch.optionStatusCodeFunc(r); code >= 200 && code <= 299 {
+			return code
+		}
+	}
+
+	return ch.optionStatusCode
+}
+
+// writePreflight completes a successful preflight request.
+func (ch *cors) writePreflight(w http.ResponseWriter, r *http.Request) {
+	status := ch.preflightStatus(r)
+	if ch.preflightBody == nil || status == http.StatusNoContent || status == http.StatusNotModified || status < 200 {
+		w.WriteHeader(status)
+		return
+	}
+
+	body := *ch.preflightBody
+	if body != "" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	io.WriteString(w, body)
+}
+
+// credentialsAllowed reports whether Access-Control-Allow-Credentials should
+// be sent for r, given the Access-Control-Allow-Origin value.
+func (ch *cors) credentialsAllowed(r *http.Request, allowOrigin string) bool {
+	// Preflights of credentialed requests always need the header, or the
+	// browser fails the request.
+	preflight := r.Method == corsOptionMethod
+	if !preflight && !ch.credentialsActual {
+		return false
+	}
+
+	if preflight && ch.preflightCredentials != nil && !ch.preflightCredentials(r) {
+		return false
+	}
+
+	if policy, ok := ch.originPolicies[r.Header.Get(corsOriginHeader)]; ok {
+		return allowOrigin != corsOriginMatchAll && policy.AllowCredentials
+	}
+
+	if ch.allowCredentialsFunc != nil {
+		return allowOrigin != corsOriginMatchAll && ch.allowCredentialsFunc(r)
+	}
+
+	return ch.allowCredentials
+}
+
+// checkOrigin reports whether origin is allowed and, if it is not, the
+// handler an OriginDecider asked to respond with.
+func (ch *cors) checkOrigin(r *http.Request, origin string, allowedOrigins []string) (bool, http.HandlerFunc) {
+	// "*" is never sent by browsers as an origin. Rejecting it here keeps a
+	// permissive validator from approving it and having it reflected.
+	if origin == "" || strings.TrimSpace(origin) == corsOriginMatchAll {
+		return false, nil
+	}
+
+	if origin == corsOriginNull {
+		if ch.forbidNullOrigin {
+			return false, nil
+		}
+		if ch.allowNullOrigin {
+			return true, nil
+		}
+	}
+
+	for _, deniedOrigin := range ch.deniedOrigins {
+		if matchOrigin(deniedOrigin, origin) {
+			return false, nil
+		}
+	}
+
+	if ch.originDecider != nil {
+		decision, deny := ch.originDecider(r, origin)
+		if decision == OriginAllow {
+			return true, nil
+		}
+		return false, deny
+	}
+
+	return ch.isOriginAllowed(r, origin, allowedOrigins), nil
+}
+
+func (ch *cors) isOriginAllowed(r *http.Request, origin string, allowedOrigins []string) bool {
+	return ch.matchAllowedOrigin(origin, allowedOrigins) || ch.matchSafeMethodOrigin(r, origin)
+}
+
+// matchSafeMethodOrigin reports whether origin is allowed by
+// SafeMethodOrigins for the method of r.
+func (ch *cors) matchSafeMethodOrigin(r *http.Request, origin string) bool {
+	return len(ch.safeMethodOrigins) > 0 && isSafeMethod(corsRequestMethod(r)) &&
+		OriginMatches(origin, ch.safeMethodOrigins, nil)
+}
+
+// isSafeMethodWildcard reports whether origin is allowed only because
+// SafeMethodOrigins contains "*". Such an origin is answered with "*" and
+// never with credentials, so that "*" does not turn into a reflector of
+// every origin.
+func (ch *cors) isSafeMethodWildcard(r *http.Request, origin string, allowedOrigins []string) bool {
+	if !isMatch(corsOriginMatchAll, ch.safeMethodOrigins) || !isSafeMethod(corsRequestMethod(r)) {
+		return false
+	}
+
+	// A decider or AllowNullOrigin allows the origin on its own.
+	if ch.originDecider != nil || (origin == corsOriginNull && ch.allowNullOrigin) {
+		return false
+	}
+
+	return !ch.matchAllowedOrigin(origin, allowedOrigins)
+}
+
+// matchAllowedOrigin reports whether origin is allowed for every method.
+func (ch *cors) matchAllowedOrigin(origin string, allowedOrigins []string) bool {
+	if _, ok := ch.originPolicies[origin]; ok {
+		return true
+	}
+
+	if ch.matchOriginGlob(origin) || ch.matchOriginSuffix(origin) || ch.matchOriginNet(origin) || ch.matchLocalhost(origin) {
+		return true
+	}
+
+	var validator OriginValidator
+	if ch.hasOriginValidator() {
+		validator = ch.validateOrigin
+	} else if ch.usesDefaultOrigin(allowedOrigins) {
 		return ch.allowDefaultOrigins
 	}
 
-	for _, allowedOrigin := range allowedOrigins {
-		if allowedOrigin == origin || allowedOrigin == corsOriginMatchAll {
+	if OriginMatches(origin, allowedOrigins, validator) {
+		return true
+	}
+
+	if validator == nil && ch.ignoreWWWPrefix {
+		return OriginMatches(trimWWWPrefix(origin), trimWWWPrefixes(allowedOrigins), nil)
+	}
+
+	return false
+}
+
+// OriginMatches reports whether origin is allowed by the same rules the CORS
+// middleware applies to its AllowedOrigins and AllowedOriginValidator
+// options. If validator is not nil it alone decides. Otherwise origin must
+// equal an entry of allowed, be covered by a subdomain wildcard entry such as
+// "https://*.example.com", or allowed must contain "*".
+func OriginMatches(origin string, allowed []string, validator OriginValidator) bool {
+	if validator != nil {
+		return validator(origin)
+	}
+
+	for _, allowedOrigin := range allowed {
+		if allowedOrigin == corsOriginMatchAll || matchOrigin(allowedOrigin, origin) {
 			return true
 		}
 	}
@@ -408,14 +1851,272 @@ func (ch *cors) isOriginAllowed(r *http.Request, origin string) bool {
 	return false
 }
 
-func (ch *cors) getAllowedOrigins(r *http.Request) []string {
+func (ch *cors) hasOriginValidator() bool {
+	return ch.allowedOriginValidator != nil || len(ch.originValidators) > 0
+}
+
+// validateOrigin reports whether any configured validator allows origin. The
+// validator set by AllowedOriginValidator is consulted first.
+func (ch *cors) validateOrigin(origin string) bool {
+	if ch.allowedOriginValidator != nil && ch.allowedOriginValidator(origin) {
+		return true
+	}
+
+	for _, fn := range ch.originValidators {
+		if fn(origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// usesDefaultOrigin reports whether no origin restriction is configured, in
+// which case allowDefaultOrigins decides whether origins are allowed and
+// defaultOrigin is sent back.
+func (ch *cors) usesDefaultOrigin(allowedOrigins []string) bool {
+	return len(allowedOrigins) == 0 &&
+		!ch.hasOriginValidator() &&
+		ch.originDecider == nil &&
+		!ch.hasOriginPatterns()
+}
+
+// hasOriginPatterns reports whether origins are matched by glob, domain
+// suffix, IP range, origin policy or safe method origins, in addition to the
+// allowed origins list.
+func (ch *cors) hasOriginPatterns() bool {
+	return len(ch.allowedOriginGlobs) > 0 || len(ch.allowedOriginSuffixes) > 0 ||
+		len(ch.allowedOriginNets) > 0 || ch.allowLocalhost || len(ch.originPolicies) > 0 ||
+		len(ch.safeMethodOrigins) > 0
+}
+
+// corsRequestMethod returns the method of the request r is made for: the
+// requested method of a preflight, or the method of r itself.
+func corsRequestMethod(r *http.Request) string {
+	if r.Method == corsOptionMethod {
+		if method := r.Header.Get(corsRequestMethodHeader); method != "" {
+			return strings.ToUpper(strings.TrimSpace(method))
+		}
+	}
+
+	return r.Method
+}
+
+// isSafeMethod reports whether method only reads a resource, for
+// SafeMethodOrigins.
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// matchOriginGlob reports whether origin matches one of the allowed origin
+// globs.
+func (ch *cors) matchOriginGlob(origin string) bool {
+	if len(ch.allowedOriginGlobs) == 0 {
+		return false
+	}
+
+	origin = strings.ToLower(origin)
+	i := strings.Index(origin, "://")
+	if i <= 0 || strings.ContainsAny(origin[i+3:], "/") {
+		return false
+	}
+	scheme, host := origin[:i+3], globHost(origin[i+3:])
+
+	for _, pattern := range ch.allowedOriginGlobs {
+		if !strings.HasPrefix(pattern, scheme) {
+			continue
+		}
+		if ok, _ := path.Match(globHost(pattern[len(scheme):]), host); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// globHost replaces the dots of host with slashes so that path.Match treats
+// each DNS label as a path element.
+func globHost(host string) string {
+	return strings.Replace(host, ".", "/", -1)
+}
+
+// matchOriginSuffix reports whether the host of origin ends with one of the
+// allowed origin suffixes.
+func (ch *cors) matchOriginSuffix(origin string) bool {
+	if len(ch.allowedOriginSuffixes) == 0 {
+		return false
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "https" && !(u.Scheme == "http" && ch.allowHTTPSuffixes)) {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for _, suffix := range ch.allowedOriginSuffixes {
+		if strings.HasSuffix(host, suffix) || host == suffix[1:] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchOriginNet reports whether origin is an http or https origin whose
+// host is an IP address within one of the allowed origin ranges.
+func (ch *cors) matchOriginNet(origin string) bool {
+	if len(ch.allowedOriginNets) == 0 {
+		return false
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+
+	ip := net.ParseIP(u.Hostname())
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range ch.allowedOriginNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchLocalhost reports whether origin is a loopback origin allowed by
+// AllowLocalhostOrigins.
+func (ch *cors) matchLocalhost(origin string) bool {
+	if !ch.allowLocalhost {
+		return false
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+
+	return false
+}
+
+// getAllowedOrigins returns the allowed origins list for r. It is called at
+// most once per request, as the list may be loaded from elsewhere; an error
+// means the list could not be loaded and the origin must be denied.
+func (ch *cors) getAllowedOrigins(r *http.Request) ([]string, error) {
 	if ch.allowedOriginsFunc != nil {
 		return ch.allowedOriginsFunc(r)
-	} else {
-		// this gets filtered on construction by the options
-		return ch.allowedOrigins
 	}
 
+	// this gets filtered on construction by the options
+	return ch.allowedOrigins, nil
+}
+
+// matchOrigin reports whether origin is equal to pattern or, when pattern
+// contains a subdomain wildcard, whether origin is a subdomain it covers.
+func matchOrigin(pattern, origin string) bool {
+	if pattern == origin {
+		return true
+	}
+
+	i := strings.Index(pattern, corsSubdomainWildcard)
+	if i == -1 {
+		return false
+	}
+
+	scheme, suffix := pattern[:i], pattern[i+1:]
+	if scheme != "" {
+		if !strings.HasPrefix(origin, scheme) {
+			return false
+		}
+		origin = origin[len(scheme):]
+	} else if j := strings.Index(origin, "://"); j != -1 {
+		origin = origin[j+len("://"):]
+	}
+
+	return len(origin) > len(suffix) && strings.HasSuffix(origin, suffix)
+}
+
+// trimWWWPrefix removes a leading "www." from the host of origin.
+func trimWWWPrefix(origin string) string {
+	host := origin
+	scheme := ""
+	if i := strings.Index(origin, "://"); i != -1 {
+		scheme, host = origin[:i+len("://")], origin[i+len("://"):]
+	}
+
+	return scheme + strings.TrimPrefix(host, "www.")
+}
+
+func trimWWWPrefixes(origins []string) []string {
+	trimmed := make([]string, len(origins))
+	for i, o := range origins {
+		trimmed[i] = trimWWWPrefix(o)
+	}
+
+	return trimmed
+}
+
+func hasSubdomainWildcard(origins []string) bool {
+	for _, o := range origins {
+		if strings.Contains(o, corsSubdomainWildcard) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isHeaderAllowed reports whether header matches one of allowed, either
+// exactly or through an entry ending in "*" that matches by prefix. A bare
+// "*" has an empty prefix and matches nothing.
+func isHeaderAllowed(header string, allowed []string) bool {
+	for _, v := range allowed {
+		if v == header {
+			return true
+		}
+
+		if len(v) > 1 && strings.HasSuffix(v, corsOriginMatchAll) && strings.HasPrefix(header, v[:len(v)-1]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isHeaderAllowedFold is like isHeaderAllowed, but compares header names
+// case-insensitively.
+func isHeaderAllowedFold(header string, allowed []string) bool {
+	for _, v := range allowed {
+		if strings.EqualFold(v, header) {
+			return true
+		}
+
+		prefix := strings.TrimSuffix(v, corsOriginMatchAll)
+		if prefix != v && prefix != "" && len(header) >= len(prefix) && strings.EqualFold(header[:len(prefix)], prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isMatchFold(needle string, haystack []string) bool {
+	for _, v := range haystack {
+		if strings.EqualFold(v, needle) {
+			return true
+		}
+	}
+
+	return false
 }
 
 func isMatch(needle string, haystack []string) bool {
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// CORSConfig is a declarative form of the CORS options, suitable for loading
+// from a configuration file. The zero value of a field leaves the
+// corresponding default in place.
+type CORSConfig struct {
+	// AllowedOrigins is used as with the AllowedOrigins option.
+	AllowedOrigins []string `json:"allowedOrigins,omitempty"`
+	// DeniedOrigins is used as with the DeniedOrigins option.
+	DeniedOrigins []string `json:"deniedOrigins,omitempty"`
+	// AllowedOriginSuffixes is used as with the AllowedOriginSuffixes option.
+	AllowedOriginSuffixes []string `json:"allowedOriginSuffixes,omitempty"`
+	// AllowedOriginsGlob is used as with the AllowedOriginsGlob option.
+	AllowedOriginsGlob []string `json:"allowedOriginsGlob,omitempty"`
+	// AllowedOriginCIDRs is used as with the AllowedOriginCIDRs option.
+	AllowedOriginCIDRs []string `json:"allowedOriginCIDRs,omitempty"`
+	// SafeMethodOrigins is used as with the SafeMethodOrigins option.
+	SafeMethodOrigins []string `json:"safeMethodOrigins,omitempty"`
+	// DisallowDefaultOrigins is used as with the DisallowDefaultOrigins option.
+	DisallowDefaultOrigins bool `json:"disallowDefaultOrigins,omitempty"`
+	// AllowNullOrigin is used as with the AllowNullOrigin option.
+	AllowNullOrigin bool `json:"allowNullOrigin,omitempty"`
+	// AllowedMethods replaces the default methods, as with the AllowedMethods
+	// option.
+	AllowedMethods []string `json:"allowedMethods,omitempty"`
+	// AllowedHeaders is added to the default headers, as with the
+	// AllowedHeaders option.
+	AllowedHeaders []string `json:"allowedHeaders,omitempty"`
+	// DeniedHeaders is used as with the DeniedHeaders option.
+	DeniedHeaders []string `json:"deniedHeaders,omitempty"`
+	// ExposedHeaders is used as with the ExposedHeaders option.
+	ExposedHeaders []string `json:"exposedHeaders,omitempty"`
+	// MaxAge is used as with the MaxAge option.
+	MaxAge int `json:"maxAge,omitempty"`
+	// AllowCredentials is used as with the AllowCredentials option.
+	AllowCredentials bool `json:"allowCredentials,omitempty"`
+	// IgnoreOptions is used as with the IgnoreOptions option.
+	IgnoreOptions bool `json:"ignoreOptions,omitempty"`
+	// OptionStatusCode is used as with the OptionStatusCode option.
+	OptionStatusCode int `json:"optionStatusCode,omitempty"`
+	// Strict is used as with the StrictCORS option.
+	Strict bool `json:"strict,omitempty"`
+}
+
+// NewCORSFromConfig provides Cross-Origin Resource Sharing middleware
+// configured by config. Unlike CORS, it returns an error instead of panicking
+// when the configuration is invalid.
+//
+// Additional options, such as those that take functions, may be passed in
+// opts and are applied after config.
+func NewCORSFromConfig(config CORSConfig, opts ...CORSOption) (func(http.Handler) http.Handler, error) {
+	if config.MaxAge < 0 {
+		return nil, errors.New("handlers: CORS MaxAge must not be negative")
+	}
+	if config.OptionStatusCode != 0 && (config.OptionStatusCode < 200 || config.OptionStatusCode > 299) {
+		return nil, errors.New("handlers: CORS OptionStatusCode must be a 2xx status")
+	}
+
+	ch, err := parseCORSOptions(append(config.options(), opts...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(h http.Handler) http.Handler {
+		c := *ch
+		c.h = h
+		return &c
+	}, nil
+}
+
+// CORSConfigOf returns the configuration of h if it is a handler returned by
+// the CORS middleware, for example to document the CORS policy of an API. The
+// boolean reports whether h is such a handler. Options that take functions
+// have no representation in CORSConfig and are not reported.
+func CORSConfigOf(h http.Handler) (CORSConfig, bool) {
+	ch, ok := h.(*cors)
+	if !ok {
+		return CORSConfig{}, false
+	}
+
+	config := CORSConfig{
+		AllowedOrigins:         copyStrings(ch.allowedOrigins),
+		DeniedOrigins:          copyStrings(ch.deniedOrigins),
+		AllowedOriginSuffixes:  copyStrings(ch.allowedOriginSuffixes),
+		AllowedOriginsGlob:     copyStrings(ch.allowedOriginGlobs),
+		SafeMethodOrigins:      copyStrings(ch.safeMethodOrigins),
+		DisallowDefaultOrigins: !ch.allowDefaultOrigins,
+		AllowNullOrigin:        ch.allowNullOrigin,
+		AllowedMethods:         copyStrings(ch.allowedMethods),
+		AllowedHeaders:         copyStrings(ch.allowedHeaders),
+		DeniedHeaders:          copyStrings(ch.deniedHeaders),
+		ExposedHeaders:         copyStrings(ch.exposedHeaders),
+		MaxAge:                 ch.maxAge,
+		AllowCredentials:       ch.allowCredentials,
+		IgnoreOptions:          ch.ignoreOptions,
+		OptionStatusCode:       ch.optionStatusCode,
+		Strict:                 ch.strict,
+	}
+	for _, n := range ch.allowedOriginNets {
+		config.AllowedOriginCIDRs = append(config.AllowedOriginCIDRs, n.String())
+	}
+
+	return config, true
+}
+
+// CORSConfigError describes every inconsistency ValidateCORS found in a CORS
+// configuration.
+type CORSConfigError struct {
+	Problems []string
+}
+
+func (e *CORSConfigError) Error() string {
+	return "handlers: invalid CORS configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// ValidateCORS checks that the configuration made by opts is internally
+// consistent, so that misconfigurations can fail a build or the start of a
+// server rather than surface in production. It returns the error of the
+// first option that fails, or a *CORSConfigError listing every problem
+// found:
+//
+//   - "*" in AllowedOrigins together with AllowCredentials;
+//   - no origin restriction at all, so that every origin is allowed by
+//     default;
+//   - a negative MaxAge, or one above the 600 second limit;
+//   - an OptionStatusCode that is not 2xx;
+//   - the configurations CORS warns about when it is constructed.
+func ValidateCORS(opts ...CORSOption) error {
+	ch := defaultCORS()
+	for _, option := range opts {
+		if err := option(ch); err != nil {
+			return err
+		}
+	}
+
+	var problems []string
+	if ch.allowCredentials && isMatch(corsOriginMatchAll, ch.allowedOrigins) {
+		problems = append(problems, `AllowedOrigins contains "*" while AllowCredentials is set`)
+	}
+	if ch.allowDefaultOrigins && ch.defaultOrigin == corsOriginMatchAll && ch.allowedOriginsFunc == nil &&
+		ch.usesDefaultOrigin(ch.allowedOrigins) {
+		problems = append(problems, "no allowed origins are configured, so every origin is allowed by default")
+	}
+	if ch.requestedMaxAge < 0 || ch.requestedMaxAge > corsMaxAgeLimit {
+		problems = append(problems, fmt.Sprintf("MaxAge of %d seconds is outside the range 0 to %d", ch.requestedMaxAge, corsMaxAgeLimit))
+	}
+	if ch.optionStatusCode < 200 || ch.optionStatusCode > 299 {
+		problems = append(problems, fmt.Sprintf("OptionStatusCode %d is not a 2xx status", ch.optionStatusCode))
+	}
+	for _, warning := range ch.configWarnings() {
+		problems = append(problems, strings.TrimPrefix(warning, "handlers: "))
+	}
+
+	if len(problems) > 0 {
+		return &CORSConfigError{Problems: problems}
+	}
+
+	return nil
+}
+
+func copyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+
+	return append([]string{}, s...)
+}
+
+// options converts config to the equivalent functional options.
+func (config CORSConfig) options() []CORSOption {
+	var opts []CORSOption
+
+	if config.AllowedOrigins != nil {
+		opts = append(opts, AllowedOrigins(config.AllowedOrigins))
+	}
+	if config.DeniedOrigins != nil {
+		opts = append(opts, DeniedOrigins(config.DeniedOrigins))
+	}
+	if config.AllowedOriginSuffixes != nil {
+		opts = append(opts, AllowedOriginSuffixes(config.AllowedOriginSuffixes))
+	}
+	if config.AllowedOriginsGlob != nil {
+		opts = append(opts, AllowedOriginsGlob(config.AllowedOriginsGlob))
+	}
+	if config.AllowedOriginCIDRs != nil {
+		opts = append(opts, AllowedOriginCIDRs(config.AllowedOriginCIDRs))
+	}
+	if config.SafeMethodOrigins != nil {
+		opts = append(opts, SafeMethodOrigins(config.SafeMethodOrigins))
+	}
+	if config.DisallowDefaultOrigins {
+		opts = append(opts, DisallowDefaultOrigins())
+	}
+	if config.AllowNullOrigin {
+		opts = append(opts, AllowNullOrigin())
+	}
+	if config.AllowedMethods != nil {
+		opts = append(opts, AllowedMethods(config.AllowedMethods))
+	}
+	if config.AllowedHeaders != nil {
+		opts = append(opts, AllowedHeaders(config.AllowedHeaders))
+	}
+	if config.DeniedHeaders != nil {
+		opts = append(opts, DeniedHeaders(config.DeniedHeaders))
+	}
+	if config.ExposedHeaders != nil {
+		opts = append(opts, ExposedHeaders(config.ExposedHeaders))
+	}
+	if config.MaxAge > 0 {
+		opts = append(opts, MaxAge(config.MaxAge))
+	}
+	if config.AllowCredentials {
+		opts = append(opts, AllowCredentials())
+	}
+	if config.IgnoreOptions {
+		opts = append(opts, IgnoreOptions())
+	}
+	if config.OptionStatusCode != 0 {
+		opts = append(opts, OptionStatusCode(config.OptionStatusCode))
+	}
+	if config.Strict {
+		opts = append(opts, StrictCORS())
+	}
+
+	return opts
+}
+
+// CORSAllowedOriginsFromEnv sets the allowed origins from the environment
+// variable varName, which holds a comma separated list such as
+// "https://a.example.com, https://*.example.org". Entries are trimmed and
+// lowercased. The option fails if the variable is unset or empty, or if an
+// entry is not "*" or a scheme and host without a path.
+func CORSAllowedOriginsFromEnv(varName string) CORSOption {
+	value := os.Getenv(varName)
+
+	return func(ch *cors) error {
+		origins, err := parseOriginList(value)
+		if err != nil {
+			return fmt.Errorf("handlers: parsing %s: %v", varName, err)
+		}
+
+		return AllowedOrigins(origins)(ch)
+	}
+}
+
+// parseOriginList parses a comma separated list of origins.
+func parseOriginList(value string) ([]string, error) {
+	var origins []string
+	for _, v := range strings.Split(value, ",") {
+		origin := strings.ToLower(strings.TrimSpace(v))
+		if origin == "" {
+			continue
+		}
+
+		if origin != corsOriginMatchAll {
+			u, err := url.Parse(strings.Replace(origin, corsSubdomainWildcard, "", 1))
+			if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+				return nil, fmt.Errorf("invalid origin %q", v)
+			}
+			origin = strings.TrimSuffix(origin, "/")
+		}
+
+		origins = append(origins, origin)
+	}
+
+	if len(origins) == 0 {
+		return nil, errors.New("no origins")
+	}
+
+	return origins, nil
+}
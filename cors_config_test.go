@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewCORSFromConfig(t *testing.T) {
+	data := `{
+		"allowedOrigins": ["https://a.com"],
+		"allowedMethods": ["GET", "PUT"],
+		"allowedHeaders": ["X-Custom"],
+		"exposedHeaders": ["x-total"],
+		"maxAge": 300,
+		"allowCredentials": true,
+		"optionStatusCode": 204
+	}`
+
+	var config CORSConfig
+	if err := json.Unmarshal([]byte(data), &config); err != nil {
+		t.Fatal(err)
+	}
+
+	middleware, err := NewCORSFromConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := middleware(okHandler)
+
+	r := newRequest("OPTIONS", "http://www.example.com/")
+	r.Header.Set("Origin", "https://a.com")
+	r.Header.Set(corsRequestMethodHeader, "PUT")
+	r.Header.Set(corsRequestHeadersHeader, "X-Custom")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+
+	if got, want := rr.Code, http.StatusNoContent; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+
+	headers := map[string]string{
+		corsAllowOriginHeader:      "https://a.com",
+		corsAllowMethodsHeader:     "PUT",
+		corsAllowHeadersHeader:     "X-Custom",
+		corsMaxAgeHeader:           "300",
+		corsAllowCredentialsHeader: "true",
+	}
+	for name, want := range headers {
+		if got := rr.Header().Get(name); got != want {
+			t.Fatalf("bad header %s: expected %q, got %q.", name, want, got)
+		}
+	}
+
+	r = newRequest("GET", "http://www.example.com/")
+	r.Header.Set("Origin", "https://a.com")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+
+	if got, want := rr.Header().Get(corsExposeHeadersHeader), "X-Total"; got != want {
+		t.Fatalf("bad header: expected %q, got %q.", want, got)
+	}
+
+	r = newRequest("GET", "http://www.example.com/")
+	r.Header.Set("Origin", "https://b.com")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+
+	if got := rr.Header().Get(corsAllowOriginHeader); got != "" {
+		t.Fatalf("bad header: expected no origin, got %q.", got)
+	}
+}
+
+func TestNewCORSFromConfigInvalid(t *testing.T) {
+	configs := []CORSConfig{
+		{MaxAge: -1},
+		{OptionStatusCode: 404},
+		{AllowedOriginCIDRs: []string{"not-a-cidr"}},
+		{AllowCredentials: true, Strict: true},
+	}
+
+	for _, config := range configs {
+		if _, err := NewCORSFromConfig(config); err == nil {
+			t.Fatalf("expected an error for %+v", config)
+		}
+	}
+}
+
+func TestCORSAllowedOriginsFromEnv(t *testing.T) {
+	const name = "HANDLERS_TEST_CORS_ORIGINS"
+	defer os.Unsetenv(name)
+
+	os.Setenv(name, " https://A.example.com/, https://*.example.org,,http://localhost:3000 ")
+	ch, err := parseCORSOptions(CORSAllowedOriginsFromEnv(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"https://a.example.com", "https://*.example.org", "http://localhost:3000"}
+	if !reflect.DeepEqual(ch.allowedOrigins, want) {
+		t.Fatalf("bad allowed origins: got %q want %q", ch.allowedOrigins, want)
+	}
+
+	for _, value := range []string{"", " , ", "example.com", "https://a.com/path"} {
+		os.Setenv(name, value)
+		if _, err := parseCORSOptions(CORSAllowedOriginsFromEnv(name)); err == nil {
+			t.Fatalf("expected an error for %q", value)
+		}
+	}
+}
+
+func TestCORSConfigOf(t *testing.T) {
+	h := CORS(
+		AllowedOrigins([]string{"https://a.com", "https://b.com"}),
+		AllowedMethods([]string{"GET", "PUT"}),
+		AllowedOriginCIDRs([]string{"10.0.0.0/8"}),
+		AllowCredentials(),
+		MaxAge(120),
+	)(okHandler)
+
+	config, ok := CORSConfigOf(h)
+	if !ok {
+		t.Fatal("expected a CORS handler")
+	}
+
+	if want := []string{"https://a.com", "https://b.com"}; !reflect.DeepEqual(config.AllowedOrigins, want) {
+		t.Fatalf("bad allowed origins: got %q want %q", config.AllowedOrigins, want)
+	}
+	if want := []string{"GET", "PUT"}; !reflect.DeepEqual(config.AllowedMethods, want) {
+		t.Fatalf("bad allowed methods: got %q want %q", config.AllowedMethods, want)
+	}
+	if want := []string{"10.0.0.0/8"}; !reflect.DeepEqual(config.AllowedOriginCIDRs, want) {
+		t.Fatalf("bad allowed CIDRs: got %q want %q", config.AllowedOriginCIDRs, want)
+	}
+	if !config.AllowCredentials || config.MaxAge != 120 {
+		t.Fatalf("bad config: %+v", config)
+	}
+
+	// The reported configuration can be used to build an equivalent handler.
+	if _, err := NewCORSFromConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := CORSConfigOf(okHandler); ok {
+		t.Fatal("expected okHandler not to be reported as a CORS handler")
+	}
+}
+
+func TestValidateCORS(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []CORSOption
+		problem string
+	}{
+		{"wildcard with credentials", []CORSOption{AllowedOrigins([]string{"*"}), AllowCredentials()}, `AllowedOrigins contains "*" while AllowCredentials is set`},
+		{"default origins", nil, "every origin is allowed by default"},
+		{"default origins with credentials", []CORSOption{AllowCredentials()}, "CORS allows credentials while every origin is allowed by default"},
+		{"negative max age", []CORSOption{AllowedOrigins([]string{"https://example.com"}), MaxAge(-1)}, "MaxAge of -1 seconds"},
+		{"max age over limit", []CORSOption{AllowedOrigins([]string{"https://example.com"}), MaxAge(3600)}, "MaxAge of 3600 seconds"},
+		{"option status", []CORSOption{AllowedOrigins([]string{"https://example.com"}), OptionStatusCode(http.StatusFound)}, "OptionStatusCode 302"},
+	}
+
+	for _, tt := range tests {
+		err := ValidateCORS(tt.opts...)
+		configErr, ok := err.(*CORSConfigError)
+		if !ok {
+			t.Fatalf("%s: expected *CORSConfigError, got %v", tt.name, err)
+		}
+
+		found := false
+		for _, problem := range configErr.Problems {
+			if strings.Contains(problem, tt.problem) {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("%s: expected problem %q, got %q", tt.name, tt.problem, configErr.Problems)
+		}
+	}
+}
+
+func TestValidateCORSValid(t *testing.T) {
+	err := ValidateCORS(AllowedOrigins([]string{"https://example.com"}), AllowCredentials(), MaxAge(600))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := ValidateCORS(AllowedOriginCIDRs([]string{"bad"})); err == nil || strings.Contains(err.Error(), "invalid CORS configuration") {
+		t.Fatalf("expected option error, got %v", err)
+	}
+}
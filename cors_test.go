@@ -1,10 +1,17 @@
 package handlers
 
 import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"log"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestDefaultCORSHandlerReturnsOk(t *testing.T) {
@@ -379,7 +386,7 @@ func TestCORSHandlerMultipleAllowOriginsFunc(t *testing.T) {
 		return []string{r.URL.String(), "http://google.com"}
 	}))(testHandler).ServeHTTP(rr, r)
 
-	if count != 2 {
+	if count != 1 {
 		t.Fatalf("bad origins func call count: got %d want 1", count)
 	}
 
@@ -474,3 +481,2024 @@ func TestCORSAllowStarDisallowDefault(t *testing.T) {
 		t.Fatalf("expected header to empty")
 	}
 }
+
+func TestCORSSubdomainWildcardWithDeniedOrigin(t *testing.T) {
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := CORS(
+		AllowedOrigins([]string{"https://*.example.com"}),
+		DeniedOrigins([]string{"https://admin.example.com"}),
+	)(testHandler)
+
+	tests := []struct {
+		origin string
+		want   string
+	}{
+		{"https://foo.example.com", "https://foo.example.com"},
+		{"https://admin.example.com", ""},
+		{"https://evil.com", ""},
+		{"https://example.com", ""},
+		{"http://foo.example.com", ""},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("GET", "http://www.example.com/")
+		r.Header.Set("Origin", tt.origin)
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got := rr.Header().Get(corsAllowOriginHeader); got != tt.want {
+			t.Fatalf("bad header for origin %q: expected %q, got %q.", tt.origin, tt.want, got)
+		}
+	}
+}
+
+func TestCORSDeniedOriginOverridesValidator(t *testing.T) {
+	r := newRequest("GET", "http://www.example.com/")
+	r.Header.Set("Origin", "https://admin.example.com")
+	rr := httptest.NewRecorder()
+
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	CORS(
+		AllowedOriginValidator(func(string) bool { return true }),
+		DeniedOrigins([]string{"https://admin.example.com"}),
+	)(testHandler).ServeHTTP(rr, r)
+
+	if got := rr.Header().Get(corsAllowOriginHeader); got != "" {
+		t.Fatalf("bad header: expected %q to be empty, got %q.", corsAllowOriginHeader, got)
+	}
+}
+
+func TestCORSDisallowedOriginEmptyHeader(t *testing.T) {
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	for _, opts := range [][]CORSOption{
+		{AllowedOrigins([]string{"https://allowed.com"})},
+		{AllowedOrigins([]string{"https://allowed.com"}), DisallowedOriginEmptyHeader()},
+	} {
+		r := newRequest("GET", "http://www.example.com/")
+		r.Header.Set("Origin", "https://evil.com")
+		rr := httptest.NewRecorder()
+
+		CORS(opts...)(testHandler).ServeHTTP(rr, r)
+
+		if got, want := rr.Code, http.StatusTeapot; got != want {
+			t.Fatalf("bad status: got %v want %v", got, want)
+		}
+
+		values, present := rr.Header()[corsAllowOriginHeader]
+		if got, want := present, len(opts) == 2; got != want {
+			t.Fatalf("bad header presence: got %v want %v", got, want)
+		}
+		if present && (len(values) != 1 || values[0] != "") {
+			t.Fatalf("bad header: expected %q to be empty, got %q.", corsAllowOriginHeader, values)
+		}
+	}
+}
+
+func TestCORSHandlerPreserveHeaderCase(t *testing.T) {
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	r := newRequest("GET", "http://www.example.com/")
+	r.Header.Set("Origin", r.URL.String())
+	rr := httptest.NewRecorder()
+
+	CORS(ExposedHeaders([]string{"X-MyApp-ID", "x-myapp-id"}), PreserveHeaderCase())(testHandler).ServeHTTP(rr, r)
+
+	if got, want := rr.Header().Get(corsExposeHeadersHeader), "X-MyApp-ID"; got != want {
+		t.Fatalf("bad header: expected %q to be %q, got %q.", corsExposeHeadersHeader, want, got)
+	}
+
+	r = newRequest("OPTIONS", "http://www.example.com/")
+	r.Header.Set("Origin", r.URL.String())
+	r.Header.Set(corsRequestMethodHeader, "GET")
+	r.Header.Set(corsRequestHeadersHeader, "X-MyApp-ID")
+	rr = httptest.NewRecorder()
+
+	CORS(AllowedHeaders([]string{"X-MyApp-ID"}), PreserveHeaderCase())(testHandler).ServeHTTP(rr, r)
+
+	if got, want := rr.Header().Get(corsAllowHeadersHeader), "X-MyApp-ID"; got != want {
+		t.Fatalf("bad header: expected %q to be %q, got %q.", corsAllowHeadersHeader, want, got)
+	}
+}
+
+func TestCORSHandlerAllowJSONContentType(t *testing.T) {
+	r := newRequest("OPTIONS", "http://www.example.com/")
+	r.Header.Set("Origin", r.URL.String())
+	r.Header.Set(corsRequestMethodHeader, "POST")
+	r.Header.Set(corsRequestHeadersHeader, "content-type")
+
+	rr := httptest.NewRecorder()
+
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	CORS(AllowJSONContentType())(testHandler).ServeHTTP(rr, r)
+
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+
+	header := rr.Header().Get(corsAllowHeadersHeader)
+	if got, want := header, "Content-Type"; got != want {
+		t.Fatalf("bad header: expected %q header, got %q header.", want, got)
+	}
+}
+
+func TestCORSHandlerReflectRequestedHeadersWithDenylist(t *testing.T) {
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := CORS(ReflectRequestedHeaders(), DeniedHeaders([]string{"authorization"}))(testHandler)
+
+	tests := []struct {
+		requested string
+		code      int
+		allowed   string
+	}{
+		{"X-Anything, x-other", http.StatusOK, "X-Anything,X-Other"},
+		{"X-Anything, Authorization", http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("OPTIONS", "http://www.example.com/")
+		r.Header.Set("Origin", r.URL.String())
+		r.Header.Set(corsRequestMethodHeader, "GET")
+		r.Header.Set(corsRequestHeadersHeader, tt.requested)
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.code; got != want {
+			t.Fatalf("bad status for %q: got %v want %v", tt.requested, got, want)
+		}
+		if got, want := rr.Header().Get(corsAllowHeadersHeader), tt.allowed; got != want {
+			t.Fatalf("bad header for %q: expected %q, got %q.", tt.requested, want, got)
+		}
+	}
+}
+
+func TestCORSAllowedOriginsLoader(t *testing.T) {
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	fast := func(r *http.Request) ([]string, error) {
+		return []string{"https://a.com"}, nil
+	}
+	slow := func(r *http.Request) ([]string, error) {
+		<-r.Context().Done()
+		return []string{"https://a.com"}, nil
+	}
+	failing := func(r *http.Request) ([]string, error) {
+		return nil, errors.New("origins unavailable")
+	}
+
+	tests := []struct {
+		loader func(r *http.Request) ([]string, error)
+		want   string
+	}{
+		{fast, "https://a.com"},
+		{slow, ""},
+		{failing, ""},
+	}
+
+	for _, tt := range tests {
+		for _, method := range []string{"GET", "OPTIONS"} {
+			r := newRequest(method, "http://www.example.com/")
+			r.Header.Set("Origin", "https://a.com")
+			r.Header.Set(corsRequestMethodHeader, "GET")
+			rr := httptest.NewRecorder()
+
+			var calls int32
+			load := tt.loader
+			loader := func(r *http.Request) ([]string, error) {
+				atomic.AddInt32(&calls, 1)
+				return load(r)
+			}
+			CORS(AllowedOriginsLoader(10*time.Millisecond, loader), AllowCredentials())(testHandler).ServeHTTP(rr, r)
+
+			if got := rr.Header().Get(corsAllowOriginHeader); got != tt.want {
+				t.Fatalf("bad header: expected %q to be %q, got %q.", corsAllowOriginHeader, tt.want, got)
+			}
+			if got := rr.Header().Get(corsAllowCredentialsHeader); tt.want == "" && got != "" {
+				t.Fatalf("bad header: expected no %s, got %q.", corsAllowCredentialsHeader, got)
+			}
+			if got := atomic.LoadInt32(&calls); got != 1 {
+				t.Fatalf("bad loader call count: got %d want 1", got)
+			}
+		}
+	}
+}
+
+func TestCORSHandlerCredentialsScope(t *testing.T) {
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		option    CORSOption
+		preflight string
+		actual    string
+	}{
+		{AllowCredentials(), "true", "true"},
+		{AllowCredentialsOnPreflightOnly(), "true", ""},
+	}
+
+	for i, tt := range tests {
+		handler := CORS(tt.option, AllowedMethods([]string{"GET", "PUT"}), AllowedHeaders([]string{"X-Custom"}))(testHandler)
+
+		// Credentialed requests that need a preflight fail in the browser
+		// unless the preflight response allows credentials too.
+		for _, method := range []string{"GET", "PUT"} {
+			r := newRequest("OPTIONS", "http://www.example.com/")
+			r.Header.Set("Origin", r.URL.String())
+			r.Header.Set(corsRequestMethodHeader, method)
+			r.Header.Set(corsRequestHeadersHeader, "X-Custom")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, r)
+
+			if got := rr.Header().Get(corsAllowCredentialsHeader); got != tt.preflight {
+				t.Fatalf("test %d: bad preflight header for %s: expected %q, got %q.", i, method, tt.preflight, got)
+			}
+		}
+
+		r := newRequest("GET", "http://www.example.com/")
+		r.Header.Set("Origin", r.URL.String())
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, r)
+
+		if got := rr.Header().Get(corsAllowCredentialsHeader); got != tt.actual {
+			t.Fatalf("test %d: bad actual header: expected %q, got %q.", i, tt.actual, got)
+		}
+	}
+}
+
+func TestCORSOriginDeciderCustomDenial(t *testing.T) {
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Origin") == "https://bad.com" {
+			t.Fatal("denied request must not be passed to next handler")
+		}
+	})
+
+	decider := func(r *http.Request, origin string) (OriginDecision, http.HandlerFunc) {
+		if origin == "https://bad.com" {
+			return OriginDeny, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+				w.Write([]byte("go away"))
+			}
+		}
+		return OriginAllow, nil
+	}
+	handler := CORS(AllowedOriginDecider(decider))(testHandler)
+
+	r := newRequest("GET", "http://www.example.com/")
+	r.Header.Set("Origin", "https://bad.com")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+
+	if got, want := rr.Code, http.StatusTeapot; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+	if got, want := rr.Body.String(), "go away"; got != want {
+		t.Fatalf("bad body: got %q want %q", got, want)
+	}
+
+	r = newRequest("GET", "http://www.example.com/")
+	r.Header.Set("Origin", "https://good.com")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+
+	if got, want := rr.Header().Get(corsAllowOriginHeader), "https://good.com"; got != want {
+		t.Fatalf("bad header: expected %q to be %q, got %q.", corsAllowOriginHeader, want, got)
+	}
+}
+
+func TestCORSNullOrigin(t *testing.T) {
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name string
+		opts []CORSOption
+		want string
+	}{
+		{"not allowed", []CORSOption{AllowedOrigins([]string{"https://a.com"})}, ""},
+		{"allowed", []CORSOption{AllowedOrigins([]string{"https://a.com"}), AllowNullOrigin()}, "null"},
+		{"listed", []CORSOption{AllowedOrigins([]string{"null"})}, "null"},
+		{"forbidden", []CORSOption{AllowedOrigins([]string{"null"}), ForbidNullReflection()}, ""},
+		{"allowed and forbidden", []CORSOption{AllowNullOrigin(), ForbidNullReflection()}, ""},
+		{"forbidden and allowed", []CORSOption{ForbidNullReflection(), AllowNullOrigin()}, ""},
+		{"validator and forbidden", []CORSOption{AllowedOriginValidator(func(string) bool { return true }), ForbidNullReflection()}, ""},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("GET", "http://www.example.com/")
+		r.Header.Set("Origin", "null")
+		rr := httptest.NewRecorder()
+
+		CORS(tt.opts...)(testHandler).ServeHTTP(rr, r)
+
+		if got := rr.Header().Get(corsAllowOriginHeader); got != tt.want {
+			t.Fatalf("%s: bad header: expected %q to be %q, got %q.", tt.name, corsAllowOriginHeader, tt.want, got)
+		}
+	}
+}
+
+func TestCORSWarnsOnDefaultOriginWithCredentials(t *testing.T) {
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	var buf bytes.Buffer
+	logger := log.New(&buf, "", 0)
+
+	CORS(CORSLogger(logger), AllowedOrigins([]string{"https://a.com"}), AllowCredentials())(testHandler)
+	if buf.Len() != 0 {
+		t.Fatalf("unexpected warning: %q", buf.String())
+	}
+
+	CORS(CORSLogger(logger), AllowCredentials())(testHandler)
+	if !strings.Contains(buf.String(), "credentials") {
+		t.Fatalf("Got log %#v, wanted substring %#v", buf.String(), "credentials")
+	}
+
+	buf.Reset()
+	CORS(CORSLogger(logger), AllowedOriginValidator(func(string) bool { return true }))(testHandler)
+	if buf.Len() != 0 {
+		t.Fatalf("unexpected warning: %q", buf.String())
+	}
+
+	CORS(CORSLogger(logger), AllowedOriginValidator(func(string) bool { return true }), AllowCredentials())(testHandler)
+	if !strings.Contains(buf.String(), "validator") {
+		t.Fatalf("Got log %#v, wanted substring %#v", buf.String(), "validator")
+	}
+}
+
+func TestCORSLogsFailingOption(t *testing.T) {
+	var buf bytes.Buffer
+	handler := CORS(AllowedOriginCIDRs([]string{"10.0.0.0/33"}), AllowedOrigins([]string{"https://a.com"}), CORSLogger(log.New(&buf, "", 0)))(okHandler)
+
+	if !strings.Contains(buf.String(), "10.0.0.0/33") {
+		t.Fatalf("Got log %#v, wanted substring %#v", buf.String(), "10.0.0.0/33")
+	}
+
+	r := newRequest("GET", "http://www.example.com/")
+	r.Header.Set("Origin", "https://a.com")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+
+	if got, want := rr.Header().Get(corsAllowOriginHeader), "https://a.com"; got != want {
+		t.Fatalf("bad header: expected %q, got %q.", want, got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected CORS to panic on a failing option in strict mode")
+		}
+	}()
+	CORS(AllowedOriginCIDRs([]string{"10.0.0.0/33"}), StrictCORS())(okHandler)
+}
+
+func TestCORSStrictPanicsOnDefaultOriginWithCredentials(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected CORS to panic in strict mode")
+		}
+	}()
+
+	CORS(StrictCORS(), AllowCredentials())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+}
+
+func TestCORSHandlerPreflightWithoutRequestHeaders(t *testing.T) {
+	r := newRequest("OPTIONS", "http://www.example.com/")
+	r.Header.Set("Origin", r.URL.String())
+	r.Header.Set(corsRequestMethodHeader, "PUT")
+
+	rr := httptest.NewRecorder()
+
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	CORS(AllowedMethods([]string{"PUT"}), AllowedHeaders([]string{"X-Custom"}))(testHandler).ServeHTTP(rr, r)
+
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+
+	if _, ok := rr.Header()[corsAllowHeadersHeader]; ok {
+		t.Fatalf("bad header: expected no %q header, got %q.", corsAllowHeadersHeader, rr.Header().Get(corsAllowHeadersHeader))
+	}
+}
+
+func TestCORSAllowedOriginSuffixes(t *testing.T) {
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		origin string
+		opts   []CORSOption
+		want   string
+	}{
+		{"https://sub.example.com", nil, "https://sub.example.com"},
+		{"https://a.b.example.com:8443", nil, "https://a.b.example.com:8443"},
+		{"https://example.com", nil, "https://example.com"},
+		{"https://evilexample.com", nil, ""},
+		{"https://example.com.evil.com", nil, ""},
+		{"https://sub.trusted.io", nil, "https://sub.trusted.io"},
+		{"http://sub.example.com", nil, ""},
+		{"http://sub.example.com", []CORSOption{AllowHTTPOriginSuffixes()}, "http://sub.example.com"},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("GET", "http://www.example.com/")
+		r.Header.Set("Origin", tt.origin)
+		rr := httptest.NewRecorder()
+
+		opts := append([]CORSOption{AllowedOriginSuffixes([]string{".example.com", "trusted.io"})}, tt.opts...)
+		CORS(opts...)(testHandler).ServeHTTP(rr, r)
+
+		if got := rr.Header().Get(corsAllowOriginHeader); got != tt.want {
+			t.Fatalf("bad header for origin %q: expected %q, got %q.", tt.origin, tt.want, got)
+		}
+		if tt.want != "" && rr.Header().Get(corsVaryHeader) != corsOriginHeader {
+			t.Fatalf("bad header for origin %q: expected %s to be %q.", tt.origin, corsVaryHeader, corsOriginHeader)
+		}
+	}
+}
+
+func TestCORSHandlerListAllowedMethods(t *testing.T) {
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	handler := CORS(
+		AllowedMethods([]string{"PUT", "GET", "delete"}),
+		AllowedMethodsFunc(func(r *http.Request) []string {
+			return []string{"PATCH", "put", "GET"}
+		}),
+		ListAllowedMethods(),
+	)(testHandler)
+
+	for _, method := range []string{"PATCH", "DELETE", "GET"} {
+		r := newRequest("OPTIONS", "http://www.example.com/")
+		r.Header.Set("Origin", r.URL.String())
+		r.Header.Set(corsRequestMethodHeader, method)
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got, want := rr.Code, http.StatusOK; got != want {
+			t.Fatalf("bad status: got %v want %v", got, want)
+		}
+
+		header := rr.Header().Get(corsAllowMethodsHeader)
+		if got, want := header, "DELETE,GET,PATCH,PUT"; got != want {
+			t.Fatalf("bad header: expected %q method header, got %q header.", want, got)
+		}
+	}
+}
+
+func TestCORSWarnsWhenMaxAgeIsClamped(t *testing.T) {
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	var buf bytes.Buffer
+	logger := log.New(&buf, "", 0)
+
+	CORS(CORSLogger(logger), MaxAge(300))(testHandler)
+	if buf.Len() != 0 {
+		t.Fatalf("unexpected warning: %q", buf.String())
+	}
+
+	CORS(MaxAge(86400), CORSLogger(logger))(testHandler)
+	for _, want := range []string{"86400", "600"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("Got log %#v, wanted substring %#v", buf.String(), want)
+		}
+	}
+}
+
+func TestCORSHandlerAllowedHeaderPrefix(t *testing.T) {
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := CORS(AllowedHeaders([]string{"x-feature-flag-*"}))(testHandler)
+
+	tests := []struct {
+		requested string
+		code      int
+		allowed   string
+	}{
+		{"x-feature-flag-dark-mode", http.StatusOK, "X-Feature-Flag-Dark-Mode"},
+		{"X-Feature-Other", http.StatusForbidden, ""},
+		{"X-Feature-Flag", http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("OPTIONS", "http://www.example.com/")
+		r.Header.Set("Origin", r.URL.String())
+		r.Header.Set(corsRequestMethodHeader, "GET")
+		r.Header.Set(corsRequestHeadersHeader, tt.requested)
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.code; got != want {
+			t.Fatalf("bad status for %q: got %v want %v", tt.requested, got, want)
+		}
+		if got, want := rr.Header().Get(corsAllowHeadersHeader), tt.allowed; got != want {
+			t.Fatalf("bad header for %q: expected %q, got %q.", tt.requested, want, got)
+		}
+	}
+}
+
+func TestCORSHandlerAllowedHeaderBareWildcard(t *testing.T) {
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, opts := range [][]CORSOption{
+		{AllowedHeaders([]string{"*"})},
+		{AllowedHeaders([]string{"*"}), FoldRequestedHeaders()},
+	} {
+		for _, requested := range []string{"Authorization", "Cookie", "X-Anything"} {
+			r := newRequest("OPTIONS", "http://www.example.com/")
+			r.Header.Set("Origin", r.URL.String())
+			r.Header.Set(corsRequestMethodHeader, "GET")
+			r.Header.Set(corsRequestHeadersHeader, requested)
+			rr := httptest.NewRecorder()
+
+			CORS(opts...)(testHandler).ServeHTTP(rr, r)
+
+			if got, want := rr.Code, http.StatusForbidden; got != want {
+				t.Fatalf("bad status for %q: got %v want %v", requested, got, want)
+			}
+		}
+	}
+}
+
+func TestCORSAllowedOriginCIDRs(t *testing.T) {
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := CORS(AllowedOriginCIDRs([]string{"10.0.0.0/24", "fd00::/8"}))(testHandler)
+
+	tests := []struct {
+		origin string
+		want   string
+	}{
+		{"http://10.0.0.5:3000", "http://10.0.0.5:3000"},
+		{"https://10.0.0.200", "https://10.0.0.200"},
+		{"http://[fd00::1]:8080", "http://[fd00::1]:8080"},
+		{"http://10.0.1.5:3000", ""},
+		{"http://internal.example.com", ""},
+		{"ftp://10.0.0.5", ""},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("GET", "http://www.example.com/")
+		r.Header.Set("Origin", tt.origin)
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got := rr.Header().Get(corsAllowOriginHeader); got != tt.want {
+			t.Fatalf("bad header for origin %q: expected %q, got %q.", tt.origin, tt.want, got)
+		}
+	}
+}
+
+func TestCORSAllowedOriginCIDRsInvalid(t *testing.T) {
+	if _, err := parseCORSOptions(AllowedOriginCIDRs([]string{"10.0.0.0/33"})); err == nil {
+		t.Fatal("expected an error for an invalid CIDR")
+	}
+}
+
+func TestCORSHeadersOnlyNeverRejects(t *testing.T) {
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	decider := func(r *http.Request, origin string) (OriginDecision, http.HandlerFunc) {
+		if origin == "https://bad.com" {
+			return OriginDeny, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			}
+		}
+		return OriginAllow, nil
+	}
+	handler := CORS(HeadersOnly(), AllowedOriginDecider(decider))(testHandler)
+
+	tests := []struct {
+		method        string
+		origin        string
+		requestMethod string
+		headers       string
+		allowOrigin   string
+	}{
+		{"GET", "https://good.com", "", "", "https://good.com"},
+		{"GET", "https://bad.com", "", "", ""},
+		{"OPTIONS", "https://bad.com", "GET", "", ""},
+		{"OPTIONS", "https://good.com", "GET", "", "https://good.com"},
+		{"OPTIONS", "https://good.com", "", "", ""},
+		{"OPTIONS", "https://good.com", "DELETE", "", ""},
+		{"OPTIONS", "https://good.com", "GET", "X-Not-Allowed", ""},
+	}
+
+	for _, tt := range tests {
+		r := newRequest(tt.method, "http://www.example.com/")
+		r.Header.Set("Origin", tt.origin)
+		if tt.requestMethod != "" {
+			r.Header.Set(corsRequestMethodHeader, tt.requestMethod)
+		}
+		if tt.headers != "" {
+			r.Header.Set(corsRequestHeadersHeader, tt.headers)
+		}
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got, want := rr.Code, http.StatusOK; got != want {
+			t.Fatalf("bad status for %+v: got %v want %v", tt, got, want)
+		}
+		if got := rr.Header().Get(corsAllowOriginHeader); got != tt.allowOrigin {
+			t.Fatalf("bad header for %+v: expected %q, got %q.", tt, tt.allowOrigin, got)
+		}
+	}
+}
+
+func TestCORSEnforceAllowOriginHeader(t *testing.T) {
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(corsAllowOriginHeader, "https://a.com, https://b.com")
+		w.Header().Add(corsAllowOriginHeader, "https://c.com")
+		w.Write([]byte(ok))
+	})
+
+	tests := []struct {
+		origin string
+		want   []string
+	}{
+		{"https://a.com", []string{"https://a.com"}},
+		{"https://evil.com", nil},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("GET", "http://www.example.com/")
+		r.Header.Set("Origin", tt.origin)
+		rr := httptest.NewRecorder()
+
+		CORS(AllowedOrigins([]string{"https://a.com", "https://b.com"}), EnforceAllowOriginHeader())(testHandler).ServeHTTP(rr, r)
+
+		if got := rr.Header()[corsAllowOriginHeader]; !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("bad header for origin %q: expected %q, got %q.", tt.origin, tt.want, got)
+		}
+	}
+}
+
+func TestCORSPreflightBody(t *testing.T) {
+	tests := []struct {
+		opts          []CORSOption
+		status        int
+		body          string
+		contentLength string
+	}{
+		{nil, http.StatusOK, "", ""},
+		{[]CORSOption{PreflightBody("")}, http.StatusOK, "", "0"},
+		{[]CORSOption{PreflightBody("OK")}, http.StatusOK, "OK", "2"},
+		{[]CORSOption{PreflightBody("OK"), OptionStatusCode(http.StatusNoContent)}, http.StatusNoContent, "", ""},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("OPTIONS", "http://www.example.com/")
+		r.Header.Set("Origin", r.URL.String())
+		r.Header.Set(corsRequestMethodHeader, "GET")
+		rr := httptest.NewRecorder()
+
+		CORS(tt.opts...)(okHandler).ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.status; got != want {
+			t.Fatalf("bad status: got %v want %v", got, want)
+		}
+		if got := rr.Body.String(); got != tt.body {
+			t.Fatalf("bad body: got %q want %q", got, tt.body)
+		}
+		if got := rr.Header().Get("Content-Length"); got != tt.contentLength {
+			t.Fatalf("bad Content-Length: got %q want %q", got, tt.contentLength)
+		}
+	}
+}
+
+func TestCORSAllowedOriginValidators(t *testing.T) {
+	firstParty := func(origin string) bool { return origin == "https://app.example.com" }
+	partners := func(origin string) bool { return origin == "https://partner.com" }
+
+	handler := CORS(
+		AllowedOrigins([]string{"https://listed.com"}),
+		AllowedOriginValidators(firstParty, partners),
+	)(okHandler)
+
+	tests := []struct {
+		origin      string
+		allowOrigin string
+	}{
+		{"https://app.example.com", "https://app.example.com"},
+		{"https://partner.com", "https://partner.com"},
+		{"https://listed.com", ""},
+		{"https://evil.com", ""},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("GET", "http://www.example.com/")
+		r.Header.Set("Origin", tt.origin)
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got := rr.Header().Get(corsAllowOriginHeader); got != tt.allowOrigin {
+			t.Fatalf("bad header for origin %q: expected %q, got %q.", tt.origin, tt.allowOrigin, got)
+		}
+	}
+}
+
+func TestCORSAuditOnPanic(t *testing.T) {
+	calls := 0
+	var matchedOrigin string
+	audit := func(r *http.Request, origin string, headers http.Header) {
+		calls++
+		matchedOrigin = origin
+	}
+
+	handler := CORS(AllowedOrigins([]string{"https://a.com"}), CORSAudit(audit))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("handler failed")
+	}))
+
+	r := newRequest("GET", "http://www.example.com/")
+	r.Header.Set("Origin", "https://a.com")
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected the handler panic to propagate")
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}()
+
+	if calls != 1 {
+		t.Fatalf("bad audit call count: got %v want %v", calls, 1)
+	}
+	if matchedOrigin != "https://a.com" {
+		t.Fatalf("bad matched origin: got %q want %q", matchedOrigin, "https://a.com")
+	}
+}
+
+func TestCORSAudit(t *testing.T) {
+	var (
+		calls         int
+		matchedOrigin string
+		audited       http.Header
+	)
+	audit := func(r *http.Request, origin string, headers http.Header) {
+		calls++
+		matchedOrigin = origin
+		audited = headers
+	}
+
+	handler := CORS(
+		AllowedOrigins([]string{"https://a.com", "https://b.com"}),
+		AllowCredentials(),
+		ExposedHeaders([]string{"X-Total"}),
+		CORSAudit(audit),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Total", "1")
+		w.Write([]byte(ok))
+	}))
+
+	r := newRequest("GET", "http://www.example.com/")
+	r.Header.Set("Origin", "https://a.com")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	want := http.Header{
+		corsAllowOriginHeader:      {"https://a.com"},
+		corsAllowCredentialsHeader: {"true"},
+		corsExposeHeadersHeader:    {"X-Total"},
+		corsVaryHeader:             {corsOriginHeader},
+	}
+	if calls != 1 {
+		t.Fatalf("bad audit call count: got %v want %v", calls, 1)
+	}
+	if matchedOrigin != "https://a.com" {
+		t.Fatalf("bad matched origin: got %q want %q", matchedOrigin, "https://a.com")
+	}
+	if !reflect.DeepEqual(audited, want) {
+		t.Fatalf("bad audited headers: got %v want %v", audited, want)
+	}
+
+	r = newRequest("GET", "http://www.example.com/")
+	r.Header.Set("Origin", "https://evil.com")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	if matchedOrigin != "" || len(audited) != 0 {
+		t.Fatalf("bad audit for disallowed origin: got %q %v", matchedOrigin, audited)
+	}
+}
+
+func TestCORSIgnoreWWWPrefix(t *testing.T) {
+	tests := []struct {
+		allowed     string
+		origin      string
+		allowOrigin string
+	}{
+		{"https://example.com", "https://example.com", "https://example.com"},
+		{"https://example.com", "https://www.example.com", "https://www.example.com"},
+		{"https://www.example.com", "https://example.com", "https://example.com"},
+		{"https://example.com", "https://api.example.com", ""},
+		{"https://example.com", "https://www.example.org", ""},
+		{"https://example.com", "http://www.example.com", ""},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("GET", "http://www.example.com/")
+		r.Header.Set("Origin", tt.origin)
+		rr := httptest.NewRecorder()
+
+		CORS(AllowedOrigins([]string{tt.allowed}), IgnoreWWWPrefix())(okHandler).ServeHTTP(rr, r)
+
+		if got := rr.Header().Get(corsAllowOriginHeader); got != tt.allowOrigin {
+			t.Fatalf("bad header for %+v: expected %q, got %q.", tt, tt.allowOrigin, got)
+		}
+		if tt.allowOrigin != "" && rr.Header().Get(corsVaryHeader) != corsOriginHeader {
+			t.Fatalf("expected Vary: Origin for %+v", tt)
+		}
+	}
+}
+
+func TestCORSRejectsWildcardOrigin(t *testing.T) {
+	configs := [][]CORSOption{
+		nil,
+		{AllowedOrigins([]string{"*"})},
+		{AllowedOriginValidator(func(string) bool { return true })},
+		{AllowedOriginDecider(func(*http.Request, string) (OriginDecision, http.HandlerFunc) { return OriginAllow, nil })},
+	}
+
+	for i, opts := range configs {
+		for _, method := range []string{"GET", "OPTIONS"} {
+			r := newRequest(method, "http://www.example.com/")
+			r.Header.Set("Origin", "*")
+			r.Header.Set(corsRequestMethodHeader, "GET")
+			rr := httptest.NewRecorder()
+
+			CORS(opts...)(okHandler).ServeHTTP(rr, r)
+
+			if got := rr.Header().Get(corsAllowOriginHeader); got != "" {
+				t.Fatalf("bad header for config %d %s: expected no origin, got %q.", i, method, got)
+			}
+		}
+	}
+}
+
+func TestOriginMatches(t *testing.T) {
+	allowed := []string{"https://a.com", "https://*.example.com"}
+	validator := func(origin string) bool { return origin == "https://validated.com" }
+
+	tests := []struct {
+		origin    string
+		allowed   []string
+		validator OriginValidator
+		want      bool
+	}{
+		{"https://a.com", allowed, nil, true},
+		{"https://b.com", allowed, nil, false},
+		{"https://api.example.com", allowed, nil, true},
+		{"https://example.com", allowed, nil, false},
+		{"https://anything.com", []string{"*"}, nil, true},
+		{"https://a.com", nil, nil, false},
+		{"https://validated.com", allowed, validator, true},
+		{"https://a.com", allowed, validator, false},
+	}
+
+	for _, tt := range tests {
+		if got := OriginMatches(tt.origin, tt.allowed, tt.validator); got != tt.want {
+			t.Fatalf("OriginMatches(%q, %q): got %v want %v", tt.origin, tt.allowed, got, tt.want)
+		}
+	}
+}
+
+func TestCORSMaxRequestedHeaders(t *testing.T) {
+	tests := []struct {
+		opts   []CORSOption
+		count  int
+		lines  int
+		empty  int
+		status int
+	}{
+		{nil, 100, 1, 0, http.StatusOK},
+		{nil, 101, 1, 0, http.StatusBadRequest},
+		{nil, 5000, 1, 0, http.StatusBadRequest},
+		{[]CORSOption{MaxRequestedHeaders(2)}, 3, 1, 0, http.StatusBadRequest},
+		{[]CORSOption{MaxRequestedHeaders(0)}, 5000, 1, 0, http.StatusOK},
+		// Headers split across several lines are counted together.
+		{[]CORSOption{MaxRequestedHeaders(2)}, 3, 3, 0, http.StatusBadRequest},
+		{nil, 101, 5, 0, http.StatusBadRequest},
+		// Empty entries are not requested headers.
+		{[]CORSOption{MaxRequestedHeaders(2)}, 0, 1, 10, http.StatusOK},
+		{[]CORSOption{MaxRequestedHeaders(2)}, 2, 2, 10, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("OPTIONS", "http://www.example.com/")
+		r.Header.Set("Origin", r.URL.String())
+		r.Header.Set(corsRequestMethodHeader, "GET")
+		for line := 0; line < tt.lines; line++ {
+			var entries []string
+			for i := line; i < tt.count; i += tt.lines {
+				entries = append(entries, "Accept")
+			}
+			for i := 0; i < tt.empty; i++ {
+				entries = append(entries, "")
+			}
+			r.Header.Add(corsRequestHeadersHeader, strings.Join(entries, ","))
+		}
+		rr := httptest.NewRecorder()
+
+		CORS(tt.opts...)(okHandler).ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.status; got != want {
+			t.Fatalf("bad status for %d headers on %d lines: got %v want %v", tt.count, tt.lines, got, want)
+		}
+	}
+}
+
+func TestCORSExposedHeadersFunc(t *testing.T) {
+	exposed := func(r *http.Request) []string {
+		if r.Header.Get("Origin") == "https://trusted.com" {
+			return []string{"x-total", "x-internal-debug", "X-Total"}
+		}
+		return []string{" x-total "}
+	}
+
+	handler := CORS(
+		AllowedOrigins([]string{"https://trusted.com", "https://partner.com"}),
+		ExposedHeaders([]string{"X-Static"}),
+		ExposedHeadersFunc(exposed),
+	)(okHandler)
+
+	tests := []struct {
+		origin  string
+		exposed string
+	}{
+		{"https://trusted.com", "X-Total,X-Internal-Debug"},
+		{"https://partner.com", "X-Total"},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("GET", "http://www.example.com/")
+		r.Header.Set("Origin", tt.origin)
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got := rr.Header().Get(corsExposeHeadersHeader); got != tt.exposed {
+			t.Fatalf("bad header for origin %q: expected %q, got %q.", tt.origin, tt.exposed, got)
+		}
+	}
+}
+
+func TestCORSLowercaseReflectedOrigin(t *testing.T) {
+	validator := func(origin string) bool { return strings.EqualFold(origin, "https://app.example.com") }
+
+	tests := []struct {
+		opts        []CORSOption
+		allowOrigin string
+	}{
+		{[]CORSOption{AllowedOriginValidator(validator)}, "HTTPS://App.Example.com"},
+		{[]CORSOption{AllowedOriginValidator(validator), LowercaseReflectedOrigin()}, "https://app.example.com"},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("GET", "http://www.example.com/")
+		r.Header.Set("Origin", "HTTPS://App.Example.com")
+		rr := httptest.NewRecorder()
+
+		CORS(tt.opts...)(okHandler).ServeHTTP(rr, r)
+
+		if got := rr.Header().Get(corsAllowOriginHeader); got != tt.allowOrigin {
+			t.Fatalf("bad header: expected %q, got %q.", tt.allowOrigin, got)
+		}
+	}
+}
+
+func TestCORSAllowLocalhostOrigins(t *testing.T) {
+	handler := CORS(AllowedOrigins([]string{"https://app.example.com"}), AllowLocalhostOrigins())(okHandler)
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"http://localhost", true},
+		{"http://localhost:3000", true},
+		{"https://localhost:8443", true},
+		{"http://127.0.0.1:8080", true},
+		{"http://[::1]:5173", true},
+		{"https://app.example.com", true},
+		{"http://localhost.evil.com", false},
+		{"http://127.0.0.2", false},
+		{"ftp://localhost", false},
+		{"https://example.com", false},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("GET", "http://www.example.com/")
+		r.Header.Set("Origin", tt.origin)
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		want := ""
+		if tt.allowed {
+			want = tt.origin
+		}
+		if got := rr.Header().Get(corsAllowOriginHeader); got != want {
+			t.Fatalf("bad header for origin %q: expected %q, got %q.", tt.origin, want, got)
+		}
+	}
+}
+
+func TestCORSWebSocketUpgradePassesThrough(t *testing.T) {
+	called := false
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		if _, ok := w.(http.Hijacker); !ok {
+			t.Fatal("ResponseWriter lost http.Hijacker interface")
+		}
+		if got := w.Header().Get(corsAllowOriginHeader); got != "" {
+			t.Fatalf("unexpected %s header: %q", corsAllowOriginHeader, got)
+		}
+	})
+
+	r := newRequest("GET", "http://www.example.com/ws")
+	r.Header.Set("Origin", "https://evil.com")
+	r.Header.Set("Connection", "keep-alive, Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+
+	CORS(
+		AllowedOrigins([]string{"https://a.com"}),
+		EnforceAllowOriginHeader(),
+		CORSAudit(func(*http.Request, string, http.Header) {}),
+	)(testHandler).ServeHTTP(fullyFeaturedResponseWriter{}, r)
+
+	if !called {
+		t.Fatal("WebSocket upgrade was not passed to the handler")
+	}
+}
+
+func TestCORSSplitHeaderValues(t *testing.T) {
+	tests := []struct {
+		opts    []CORSOption
+		exposed []string
+		allowed []string
+	}{
+		{nil, []string{"X-A,X-B"}, []string{"X-A,X-B"}},
+		{[]CORSOption{SplitHeaderValues()}, []string{"X-A", "X-B"}, []string{"X-A", "X-B"}},
+	}
+
+	for _, tt := range tests {
+		opts := append([]CORSOption{
+			AllowedHeaders([]string{"X-A", "X-B"}),
+			ExposedHeaders([]string{"X-A", "X-B"}),
+		}, tt.opts...)
+		handler := CORS(opts...)(okHandler)
+
+		r := newRequest("GET", "http://www.example.com/")
+		r.Header.Set("Origin", r.URL.String())
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, r)
+
+		if got := rr.Header()[corsExposeHeadersHeader]; !reflect.DeepEqual(got, tt.exposed) {
+			t.Fatalf("bad %s: expected %q, got %q.", corsExposeHeadersHeader, tt.exposed, got)
+		}
+
+		r = newRequest("OPTIONS", "http://www.example.com/")
+		r.Header.Set("Origin", r.URL.String())
+		r.Header.Set(corsRequestMethodHeader, "GET")
+		r.Header.Set(corsRequestHeadersHeader, "X-A, X-B")
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, r)
+
+		if got := rr.Header()[corsAllowHeadersHeader]; !reflect.DeepEqual(got, tt.allowed) {
+			t.Fatalf("bad %s: expected %q, got %q.", corsAllowHeadersHeader, tt.allowed, got)
+		}
+	}
+}
+
+func TestCORSAllowCredentialsFunc(t *testing.T) {
+	tenantAllowsCredentials := func(r *http.Request) bool {
+		return strings.HasPrefix(r.URL.Path, "/tenant-a/")
+	}
+
+	tests := []struct {
+		origins     []string
+		path        string
+		allowOrigin string
+		credentials string
+	}{
+		{[]string{"https://app.com"}, "/tenant-a/users", "https://app.com", "true"},
+		{[]string{"https://app.com"}, "/tenant-b/users", "https://app.com", ""},
+		{[]string{"*"}, "/tenant-a/users", "*", ""},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("GET", "http://www.example.com"+tt.path)
+		r.Header.Set("Origin", "https://app.com")
+		rr := httptest.NewRecorder()
+
+		CORS(AllowedOrigins(tt.origins), AllowCredentialsFunc(tenantAllowsCredentials))(okHandler).ServeHTTP(rr, r)
+
+		if got := rr.Header().Get(corsAllowOriginHeader); got != tt.allowOrigin {
+			t.Fatalf("bad header for %s: expected %q, got %q.", tt.path, tt.allowOrigin, got)
+		}
+		if got := rr.Header().Get(corsAllowCredentialsHeader); got != tt.credentials {
+			t.Fatalf("bad credentials header for %s: expected %q, got %q.", tt.path, tt.credentials, got)
+		}
+	}
+}
+
+func TestCORSSimplePreflight(t *testing.T) {
+	passed := false
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		passed = true
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	tests := []struct {
+		opt     CORSOption
+		method  string
+		headers string
+		status  int
+		passed  bool
+	}{
+		{SimplePreflightStatus(http.StatusNoContent), "GET", "", http.StatusNoContent, false},
+		{SimplePreflightStatus(http.StatusNoContent), "POST", "Accept, Accept-Language", http.StatusNoContent, false},
+		{SimplePreflightStatus(http.StatusNoContent), "PUT", "", http.StatusOK, false},
+		{SimplePreflightStatus(http.StatusNoContent), "GET", "X-Custom", http.StatusOK, false},
+		{PassThroughSimplePreflights(), "GET", "", http.StatusTeapot, true},
+		{PassThroughSimplePreflights(), "PUT", "", http.StatusOK, false},
+	}
+
+	for _, tt := range tests {
+		passed = false
+		r := newRequest("OPTIONS", "http://www.example.com/")
+		r.Header.Set("Origin", r.URL.String())
+		r.Header.Set(corsRequestMethodHeader, tt.method)
+		if tt.headers != "" {
+			r.Header.Set(corsRequestHeadersHeader, tt.headers)
+		}
+		rr := httptest.NewRecorder()
+
+		CORS(tt.opt, AllowedMethods([]string{"GET", "POST", "PUT"}), AllowedHeaders([]string{"X-Custom"}), MaxAge(60))(testHandler).ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.status; got != want {
+			t.Fatalf("bad status for %s %q: got %v want %v", tt.method, tt.headers, got, want)
+		}
+		if passed != tt.passed {
+			t.Fatalf("bad pass through for %s %q: got %v want %v", tt.method, tt.headers, passed, tt.passed)
+		}
+
+		unnecessary := tt.status == http.StatusNoContent
+		if unnecessary && (rr.Header().Get(corsMaxAgeHeader) != "" || rr.Header().Get(corsAllowOriginHeader) == "") {
+			t.Fatalf("expected minimal headers for %s %q, got %v", tt.method, tt.headers, rr.Header())
+		}
+		if tt.status == http.StatusOK && rr.Header().Get(corsMaxAgeHeader) == "" {
+			t.Fatalf("expected a full preflight response for %s %q, got %v", tt.method, tt.headers, rr.Header())
+		}
+	}
+}
+
+func TestCORSAllowPrivateNetwork(t *testing.T) {
+	trusted := func(r *http.Request, origin string) bool { return origin == "https://trusted.com" }
+
+	tests := []struct {
+		opt     CORSOption
+		origin  string
+		request string
+		want    string
+	}{
+		{AllowPrivateNetwork(), "https://allowed.com", "true", "true"},
+		{AllowPrivateNetwork(), "https://allowed.com", "", ""},
+		{AllowPrivateNetwork(trusted), "https://trusted.com", "true", "true"},
+		{AllowPrivateNetwork(trusted), "https://allowed.com", "true", ""},
+		{AllowPrivateNetwork(trusted), "https://evil.com", "true", ""},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("OPTIONS", "http://www.example.com/")
+		r.Header.Set("Origin", tt.origin)
+		r.Header.Set(corsRequestMethodHeader, "GET")
+		if tt.request != "" {
+			r.Header.Set(corsRequestPrivateNetwork, tt.request)
+		}
+		rr := httptest.NewRecorder()
+
+		CORS(tt.opt, AllowedOrigins([]string{"https://trusted.com", "https://allowed.com"}))(okHandler).ServeHTTP(rr, r)
+
+		if got := rr.Header().Get(corsAllowPrivateNetwork); got != tt.want {
+			t.Fatalf("bad header for %+v: expected %q, got %q.", tt, tt.want, got)
+		}
+	}
+}
+
+func TestCORSVaryHeaders(t *testing.T) {
+	tenantHeaders := func(r *http.Request) []string {
+		if r.Header.Get("X-Tenant") == "a" {
+			return []string{"X-Tenant-A"}
+		}
+		return nil
+	}
+
+	tests := []struct {
+		opts   []CORSOption
+		method string
+		vary   string
+	}{
+		{[]CORSOption{AllowedHeadersFunc(tenantHeaders)}, "GET", "Origin"},
+		{[]CORSOption{AllowedHeadersFunc(tenantHeaders), VaryHeaders("x-tenant")}, "GET", "Origin, X-Tenant"},
+		{[]CORSOption{AllowedHeadersFunc(tenantHeaders), VaryHeaders("X-Tenant")}, "OPTIONS", "Origin, X-Tenant, Access-Control-Request-Headers"},
+		{[]CORSOption{ReflectRequestedHeaders()}, "OPTIONS", "Access-Control-Request-Headers"},
+		{[]CORSOption{VaryHeaders("X-Tenant")}, "GET", ""},
+	}
+
+	for i, tt := range tests {
+		r := newRequest(tt.method, "http://www.example.com/")
+		r.Header.Set("Origin", r.URL.String())
+		r.Header.Set(corsRequestMethodHeader, "GET")
+		rr := httptest.NewRecorder()
+
+		CORS(tt.opts...)(okHandler).ServeHTTP(rr, r)
+
+		if got := rr.Header().Get(corsVaryHeader); got != tt.vary {
+			t.Fatalf("test %d: bad Vary header: expected %q, got %q.", i, tt.vary, got)
+		}
+	}
+}
+
+func TestCORSAllowOriginValue(t *testing.T) {
+	const origin = "https://A.com"
+	validator := func(string) bool { return true }
+	originsFunc := func(*http.Request) []string { return []string{origin} }
+	wildcardFunc := func(*http.Request) []string { return []string{"*"} }
+
+	tests := []struct {
+		name string
+		opts []CORSOption
+		want string
+	}{
+		{"defaults", nil, "*"},
+		{"literal list", []CORSOption{AllowedOrigins([]string{origin, "https://b.com"})}, origin},
+		{"wildcard list", []CORSOption{AllowedOrigins([]string{"*"})}, "*"},
+		{"wildcard list with others", []CORSOption{AllowedOrigins([]string{origin, "*"})}, "*"},
+		{"validator", []CORSOption{AllowedOriginValidator(validator)}, origin},
+		{"validator with list", []CORSOption{AllowedOrigins([]string{"https://b.com"}), AllowedOriginValidator(validator)}, origin},
+		{"validator with wildcard list", []CORSOption{AllowedOrigins([]string{"*"}), AllowedOriginValidator(validator)}, "*"},
+		{"validator without defaults", []CORSOption{DisallowDefaultOrigins(), AllowedOriginValidator(validator)}, origin},
+		{"origins func", []CORSOption{AllowedOriginsFunc(originsFunc)}, origin},
+		{"wildcard origins func", []CORSOption{AllowedOriginsFunc(wildcardFunc)}, "*"},
+		{"suffix pattern", []CORSOption{AllowedOriginSuffixes([]string{"A.com"})}, origin},
+		{"lowercased", []CORSOption{AllowedOrigins([]string{origin}), LowercaseReflectedOrigin()}, "https://a.com"},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("GET", "http://www.example.com/")
+		r.Header.Set("Origin", origin)
+		rr := httptest.NewRecorder()
+
+		CORS(append([]CORSOption{CORSLogger(log.New(ioutil.Discard, "", 0))}, tt.opts...)...)(okHandler).ServeHTTP(rr, r)
+
+		if got := rr.Header().Get(corsAllowOriginHeader); got != tt.want {
+			t.Fatalf("%s: bad header: expected %q, got %q.", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestCORSPreflightCredentialsFunc(t *testing.T) {
+	usesCookies := func(r *http.Request) bool { return strings.HasPrefix(r.URL.Path, "/session/") }
+
+	tests := []struct {
+		opts        []CORSOption
+		method      string
+		path        string
+		credentials string
+	}{
+		{[]CORSOption{AllowCredentials()}, "OPTIONS", "/public/", "true"},
+		{[]CORSOption{AllowCredentials(), PreflightCredentialsFunc(usesCookies)}, "OPTIONS", "/session/", "true"},
+		{[]CORSOption{AllowCredentials(), PreflightCredentialsFunc(usesCookies)}, "OPTIONS", "/public/", ""},
+		{[]CORSOption{AllowCredentials(), PreflightCredentialsFunc(usesCookies)}, "GET", "/public/", "true"},
+	}
+
+	for i, tt := range tests {
+		r := newRequest(tt.method, "http://www.example.com"+tt.path)
+		r.Header.Set("Origin", "https://a.com")
+		r.Header.Set(corsRequestMethodHeader, "GET")
+		rr := httptest.NewRecorder()
+
+		CORS(append(tt.opts, AllowedOrigins([]string{"https://a.com"}))...)(okHandler).ServeHTTP(rr, r)
+
+		if got := rr.Header().Get(corsAllowCredentialsHeader); got != tt.credentials {
+			t.Fatalf("test %d: bad credentials header: expected %q, got %q.", i, tt.credentials, got)
+		}
+	}
+}
+
+func TestCORSPreflightVaryOnRequestedHeaders(t *testing.T) {
+	handler := CORS(AllowedHeaders([]string{"X-A", "X-B"}))(okHandler)
+
+	tests := []struct {
+		requested string
+		allowed   string
+	}{
+		{"X-A", "X-A"},
+		{"X-A, X-B", "X-A,X-B"},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("OPTIONS", "http://www.example.com/")
+		r.Header.Set("Origin", r.URL.String())
+		r.Header.Set(corsRequestMethodHeader, "GET")
+		r.Header.Set(corsRequestHeadersHeader, tt.requested)
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got := rr.Header().Get(corsAllowHeadersHeader); got != tt.allowed {
+			t.Fatalf("bad header for %q: expected %q, got %q.", tt.requested, tt.allowed, got)
+		}
+		if got, want := rr.Header().Get(corsVaryHeader), corsRequestHeadersHeader; got != want {
+			t.Fatalf("bad Vary header for %q: expected %q, got %q.", tt.requested, want, got)
+		}
+	}
+}
+
+func TestCORSWildcardMethodsWithDeniedMethods(t *testing.T) {
+	handler := CORS(AllowedMethods([]string{"*"}), DeniedMethods([]string{"trace", "CONNECT"}))(okHandler)
+
+	tests := []struct {
+		method       string
+		status       int
+		allowMethods string
+	}{
+		{"PATCH", http.StatusOK, "PATCH"},
+		{"PURGE", http.StatusOK, "PURGE"},
+		{"GET", http.StatusOK, ""},
+		{"TRACE", http.StatusMethodNotAllowed, ""},
+		{"CONNECT", http.StatusMethodNotAllowed, ""},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("OPTIONS", "http://www.example.com/")
+		r.Header.Set("Origin", r.URL.String())
+		r.Header.Set(corsRequestMethodHeader, tt.method)
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.status; got != want {
+			t.Fatalf("bad status for %s: got %v want %v", tt.method, got, want)
+		}
+		if got := rr.Header().Get(corsAllowMethodsHeader); got != tt.allowMethods {
+			t.Fatalf("bad header for %s: expected %q, got %q.", tt.method, tt.allowMethods, got)
+		}
+	}
+}
+
+func TestCORSAllowedOriginsGlob(t *testing.T) {
+	handler := CORS(AllowedOriginsGlob([]string{
+		"https://*.dev.*.example.com",
+		"http://app-??.example.org:*",
+	}))(okHandler)
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://api.dev.eu.example.com", true},
+		{"https://API.dev.us.Example.com", true},
+		{"https://dev.eu.example.com", false},
+		{"https://a.b.dev.eu.example.com", false},
+		{"https://api.dev.eu.example.com.evil.com", false},
+		{"http://api.dev.eu.example.com", false},
+		{"https://api.dev.eu.example.com:8443", false},
+		{"http://app-01.example.org:8080", true},
+		{"http://app-001.example.org:8080", false},
+		{"https://app-01.example.org:8080", false},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("GET", "http://www.example.com/")
+		r.Header.Set("Origin", tt.origin)
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		want := ""
+		if tt.allowed {
+			want = tt.origin
+		}
+		if got := rr.Header().Get(corsAllowOriginHeader); got != want {
+			t.Fatalf("bad header for %s: expected %q, got %q.", tt.origin, want, got)
+		}
+		if got := rr.Header().Get("Vary"); tt.allowed && got != corsOriginHeader {
+			t.Fatalf("bad Vary for %s: expected %q, got %q.", tt.origin, corsOriginHeader, got)
+		}
+	}
+}
+
+func TestCORSAllowedOriginsGlobSchemeBoundary(t *testing.T) {
+	handler := CORS(AllowedOriginsGlob([]string{"https://*"}))(okHandler)
+
+	for _, origin := range []string{"http://https://example", "http://example", "https://example.com"} {
+		r := newRequest("GET", "http://www.example.com/")
+		r.Header.Set("Origin", origin)
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got := rr.Header().Get(corsAllowOriginHeader); got != "" {
+			t.Fatalf("bad header for %s: expected no origin, got %q.", origin, got)
+		}
+	}
+
+	for _, pattern := range []string{"*.example.com", "http*://example.com", "https://[a-"} {
+		if _, err := parseCORSOptions(AllowedOriginsGlob([]string{pattern})); err == nil {
+			t.Fatalf("expected error for glob %q", pattern)
+		}
+	}
+}
+
+func TestCORSOptionStatusCodeFunc(t *testing.T) {
+	handler := CORS(
+		AllowedOrigins([]string{"https://legacy.example.com", "https://app.example.com"}),
+		OptionStatusCodeFunc(func(r *http.Request) int {
+			switch r.Header.Get("Origin") {
+			case "https://legacy.example.com":
+				return http.StatusOK
+			case "https://app.example.com":
+				return http.StatusNoContent
+			}
+			return http.StatusTeapot
+		}),
+	)(okHandler)
+
+	tests := []struct {
+		origin string
+		status int
+	}{
+		{"https://legacy.example.com", http.StatusOK},
+		{"https://app.example.com", http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("OPTIONS", "http://www.example.com/")
+		r.Header.Set("Origin", tt.origin)
+		r.Header.Set(corsRequestMethodHeader, "GET")
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.status; got != want {
+			t.Fatalf("bad status for %s: got %v want %v", tt.origin, got, want)
+		}
+	}
+}
+
+func TestCORSOptionStatusCodeFuncInvalidStatus(t *testing.T) {
+	handler := CORS(
+		OptionStatusCode(http.StatusNoContent),
+		OptionStatusCodeFunc(func(r *http.Request) int { return http.StatusTeapot }),
+	)(okHandler)
+
+	r := newRequest("OPTIONS", "http://www.example.com/")
+	r.Header.Set("Origin", r.URL.String())
+	r.Header.Set(corsRequestMethodHeader, "GET")
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, r)
+
+	if got, want := rr.Code, http.StatusNoContent; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+}
+
+func TestCORSWildcardOnlyPreflight(t *testing.T) {
+	configs := [][]CORSOption{
+		{},
+		{AllowedOrigins([]string{"*"}), AllowedHeaders([]string{"X-Custom"}), MaxAge(600)},
+		{AllowedOrigins([]string{"*"}), AllowedMethods([]string{"GET", "PUT"}), ListAllowedMethods()},
+	}
+
+	requests := []struct {
+		origin  string
+		method  string
+		headers string
+	}{
+		{"https://example.com", "GET", ""},
+		{"https://example.com", "PUT", "X-Custom"},
+		{"https://example.com", "DELETE", ""},
+		{"https://example.com", "GET", "X-Denied"},
+		{"null", "GET", ""},
+		{"*", "GET", ""},
+	}
+
+	for i, opts := range configs {
+		ch, err := parseCORSOptions(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ch.wildcardOnly {
+			t.Fatalf("config %d: expected wildcard-only fast path", i)
+		}
+		ch.h = okHandler
+		slow := *ch
+		slow.wildcardOnly = false
+
+		for _, tt := range requests {
+			r := newRequest("OPTIONS", "http://www.example.com/")
+			r.Header.Set("Origin", tt.origin)
+			r.Header.Set(corsRequestMethodHeader, tt.method)
+			if tt.headers != "" {
+				r.Header.Set(corsRequestHeadersHeader, tt.headers)
+			}
+
+			fastRR := httptest.NewRecorder()
+			ch.ServeHTTP(fastRR, r)
+			slowRR := httptest.NewRecorder()
+			slow.ServeHTTP(slowRR, r)
+
+			if got, want := fastRR.Code, slowRR.Code; got != want {
+				t.Fatalf("config %d, %+v: bad status: got %v want %v", i, tt, got, want)
+			}
+			if got, want := fastRR.Header(), slowRR.Header(); !reflect.DeepEqual(got, want) {
+				t.Fatalf("config %d, %+v: bad headers: got %v want %v", i, tt, got, want)
+			}
+		}
+	}
+
+	for _, opts := range [][]CORSOption{
+		{AllowedOrigins([]string{"https://example.com"})},
+		{AllowedOrigins([]string{"*"}), AllowCredentials()},
+		{AllowedOrigins([]string{"*"}), DeniedOrigins([]string{"https://evil.com"})},
+	} {
+		ch, err := parseCORSOptions(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ch.wildcardOnly {
+			t.Fatal("unexpected wildcard-only fast path")
+		}
+	}
+}
+
+func BenchmarkCORSWildcardPreflight(b *testing.B) {
+	handler := CORS(AllowedOrigins([]string{"*"}), AllowedHeaders([]string{"X-Custom"}))(okHandler)
+
+	r := newRequest("OPTIONS", "http://www.example.com/")
+	r.Header.Set("Origin", "https://example.com")
+	r.Header.Set(corsRequestMethodHeader, "PUT")
+	r.Header.Set(corsRequestHeadersHeader, "X-Custom")
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+}
+
+func TestCORSNotModifiedResponse(t *testing.T) {
+	notModified := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("body"))
+	})
+	cors := CORS(AllowedOrigins([]string{"https://a.example.com", "https://b.example.com"}))
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		vary    []string
+	}{
+		{"cors", cors(notModified), []string{"Origin"}},
+		{"compress outside cors", CompressHandler(cors(notModified)), []string{"Accept-Encoding", "Origin"}},
+		{"content length outside cors", ContentLength(1024)(cors(notModified)), []string{"Origin"}},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("GET", "http://www.example.com/")
+		r.Header.Set("Origin", "https://a.example.com")
+		r.Header.Set("If-None-Match", `"v1"`)
+		r.Header.Set(acceptEncoding, "gzip")
+		rr := httptest.NewRecorder()
+
+		tt.handler.ServeHTTP(rr, r)
+
+		if got, want := rr.Code, http.StatusNotModified; got != want {
+			t.Fatalf("%s: bad status: got %v want %v", tt.name, got, want)
+		}
+		if got, want := rr.Header().Get(corsAllowOriginHeader), "https://a.example.com"; got != want {
+			t.Fatalf("%s: bad header: expected %q, got %q.", tt.name, want, got)
+		}
+		if got := rr.Header()[corsVaryHeader]; !reflect.DeepEqual(got, tt.vary) {
+			t.Fatalf("%s: bad Vary: expected %q, got %q.", tt.name, tt.vary, got)
+		}
+	}
+}
+
+func TestCORSFoldRequestedHeaders(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []CORSOption
+		requested string
+		status    int
+		allow     string
+	}{
+		{"canonical", nil, "Sec-WebSocket-Protocol", http.StatusOK, "Sec-Websocket-Protocol"},
+		{"fold", []CORSOption{FoldRequestedHeaders()}, "Sec-WebSocket-Protocol", http.StatusOK, "Sec-WebSocket-Protocol"},
+		{"fold lowercase", []CORSOption{FoldRequestedHeaders()}, "sec-websocket-protocol", http.StatusOK, "sec-websocket-protocol"},
+		{"fold prefix", []CORSOption{FoldRequestedHeaders()}, "SEC-WEBSOCKET-EXTENSIONS", http.StatusOK, "SEC-WEBSOCKET-EXTENSIONS"},
+		{"fold denied", []CORSOption{FoldRequestedHeaders(), DeniedHeaders([]string{"sec-websocket-key"})}, "Sec-WebSocket-Key", http.StatusForbidden, ""},
+		{"fold not allowed", []CORSOption{FoldRequestedHeaders()}, "X-Other", http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		opts := append([]CORSOption{AllowedHeaders([]string{"Sec-WebSocket-Protocol", "Sec-WebSocket-Ext*", "Sec-WebSocket-Key"})}, tt.opts...)
+
+		r := newRequest("OPTIONS", "http://www.example.com/")
+		r.Header.Set("Origin", r.URL.String())
+		r.Header.Set(corsRequestMethodHeader, "GET")
+		r.Header.Set(corsRequestHeadersHeader, tt.requested)
+		rr := httptest.NewRecorder()
+
+		CORS(opts...)(okHandler).ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.status; got != want {
+			t.Fatalf("%s: bad status: got %v want %v", tt.name, got, want)
+		}
+		if got := rr.Header().Get(corsAllowHeadersHeader); got != tt.allow {
+			t.Fatalf("%s: bad header: expected %q, got %q.", tt.name, tt.allow, got)
+		}
+	}
+}
+
+func TestCORSReflectOriginTransform(t *testing.T) {
+	stripDefaultPort := func(origin string) string {
+		if strings.HasPrefix(origin, "https://") {
+			return strings.TrimSuffix(origin, ":443")
+		}
+		return strings.TrimSuffix(origin, ":80")
+	}
+	handler := CORS(
+		AllowedOrigins([]string{"https://app.example.com:443", "http://other.example.com"}),
+		ReflectOriginTransform(stripDefaultPort),
+	)(okHandler)
+
+	tests := []struct {
+		origin string
+		want   string
+	}{
+		{"https://app.example.com:443", "https://app.example.com"},
+		// The transform does not widen the allowlist.
+		{"https://app.example.com", ""},
+		{"http://other.example.com", "http://other.example.com"},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("GET", "http://www.example.com/")
+		r.Header.Set("Origin", tt.origin)
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got := rr.Header().Get(corsAllowOriginHeader); got != tt.want {
+			t.Fatalf("bad header for %s: expected %q, got %q.", tt.origin, tt.want, got)
+		}
+	}
+}
+
+func TestCORSValidateFetchMetadata(t *testing.T) {
+	tests := []struct {
+		name         string
+		allowMissing bool
+		method       string
+		site         string
+		status       int
+	}{
+		{"same-origin", false, "GET", "same-origin", http.StatusOK},
+		{"same-site", false, "GET", "Same-Site", http.StatusOK},
+		{"cross-site", false, "GET", "cross-site", http.StatusForbidden},
+		{"cross-site preflight", false, "OPTIONS", "cross-site", http.StatusForbidden},
+		{"missing denied", false, "GET", "", http.StatusForbidden},
+		{"missing allowed", true, "GET", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		handler := CORS(
+			AllowedOrigins([]string{"https://app.example.com"}),
+			AllowCredentials(),
+			ValidateFetchMetadata([]string{"same-origin", "same-site"}, tt.allowMissing),
+		)(okHandler)
+
+		r := newRequest(tt.method, "http://www.example.com/")
+		r.Header.Set("Origin", "https://app.example.com")
+		r.Header.Set(corsRequestMethodHeader, "GET")
+		if tt.site != "" {
+			r.Header.Set("Sec-Fetch-Site", tt.site)
+		}
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.status; got != want {
+			t.Fatalf("%s: bad status: got %v want %v", tt.name, got, want)
+		}
+	}
+}
+
+func TestCORSAllowOriginOnSuccess(t *testing.T) {
+	handler := CORS(
+		AllowedOrigins([]string{"https://app.example.com"}),
+		AllowCredentials(),
+		AllowOriginOnSuccess(),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/forbidden":
+			http.Error(w, "forbidden", http.StatusForbidden)
+		case "/empty":
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+
+	tests := []struct {
+		path  string
+		allow string
+	}{
+		{"/ok", "https://app.example.com"},
+		{"/empty", "https://app.example.com"},
+		{"/forbidden", ""},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("GET", "http://www.example.com"+tt.path)
+		r.Header.Set("Origin", "https://app.example.com")
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got := rr.Header().Get(corsAllowOriginHeader); got != tt.allow {
+			t.Fatalf("bad header for %s: expected %q, got %q.", tt.path, tt.allow, got)
+		}
+		if got, want := rr.Header().Get(corsAllowCredentialsHeader) != "", tt.allow != ""; got != want {
+			t.Fatalf("bad credentials header for %s: got %v want %v", tt.path, got, want)
+		}
+	}
+}
+
+func TestCORSHealthCheckPreflights(t *testing.T) {
+	handler := CORS(
+		AllowedOrigins([]string{"https://app.example.com", "https://admin.example.com"}),
+		OptionStatusCode(http.StatusNoContent),
+		HealthCheckPreflights([]string{"Pingdom"}, []string{"/healthz"}),
+	)(okHandler)
+
+	tests := []struct {
+		name      string
+		path      string
+		userAgent string
+		origin    string
+		status    int
+		allow     string
+	}{
+		{"allowed user agent", "/", "Pingdom.com_bot_version_1.4", "", http.StatusNoContent, "https://app.example.com"},
+		{"allowed path", "/healthz", "curl/7.68.0", "", http.StatusNoContent, "https://app.example.com"},
+		{"other tool", "/", "curl/7.68.0", "", http.StatusOK, ""},
+		{"browser", "/", "Pingdom", "https://evil.example.com", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("OPTIONS", "http://www.example.com"+tt.path)
+		r.Header.Set("User-Agent", tt.userAgent)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+			r.Header.Set(corsRequestMethodHeader, "GET")
+		}
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.status; got != want {
+			t.Fatalf("%s: bad status: got %v want %v", tt.name, got, want)
+		}
+		if got := rr.Header().Get(corsAllowOriginHeader); got != tt.allow {
+			t.Fatalf("%s: bad header: expected %q, got %q.", tt.name, tt.allow, got)
+		}
+	}
+}
+
+func TestCORSOriginValidatorSetsVary(t *testing.T) {
+	handler := CORS(
+		AllowedOriginValidator(func(origin string) bool {
+			return strings.HasSuffix(origin, ".example.com")
+		}),
+	)(okHandler)
+
+	r := newRequest("GET", "http://www.example.com/")
+	r.Header.Set("Origin", "https://app.example.com")
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, r)
+
+	if got, want := rr.Header().Get(corsAllowOriginHeader), "https://app.example.com"; got != want {
+		t.Fatalf("bad header: expected %q, got %q.", want, got)
+	}
+	if got, want := rr.Header().Get(corsVaryHeader), corsOriginHeader; got != want {
+		t.Fatalf("bad Vary: expected %q, got %q.", want, got)
+	}
+}
+
+func TestCORSExposeHeadersOnlyWithBody(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/empty" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+
+	tests := []struct {
+		name   string
+		opts   []CORSOption
+		path   string
+		expose string
+	}{
+		{"default 204", nil, "/empty", "X-Request-Id"},
+		{"option 204", []CORSOption{ExposeHeadersOnlyWithBody()}, "/empty", ""},
+		{"option 200", []CORSOption{ExposeHeadersOnlyWithBody()}, "/", "X-Request-Id"},
+	}
+
+	for _, tt := range tests {
+		opts := append([]CORSOption{ExposedHeaders([]string{"X-Request-Id"})}, tt.opts...)
+
+		r := newRequest("GET", "http://www.example.com"+tt.path)
+		r.Header.Set("Origin", r.URL.String())
+		rr := httptest.NewRecorder()
+
+		CORS(opts...)(handler).ServeHTTP(rr, r)
+
+		if got := rr.Header().Get(corsExposeHeadersHeader); got != tt.expose {
+			t.Fatalf("%s: bad header: expected %q, got %q.", tt.name, tt.expose, got)
+		}
+		if rr.Header().Get(corsAllowOriginHeader) == "" {
+			t.Fatalf("%s: missing %s", tt.name, corsAllowOriginHeader)
+		}
+	}
+}
+
+func TestCORSOriginPolicies(t *testing.T) {
+	handler := CORS(
+		AllowedOrigins([]string{"https://www.example.com"}),
+		MaxAge(300),
+		OriginPolicies(map[string]OriginPolicy{
+			"https://app.example.com":   {Methods: []string{"GET", "put"}, MaxAge: 600, AllowCredentials: true},
+			"https://admin.example.com": {Methods: []string{"GET", "DELETE"}, Headers: []string{"X-Admin"}, MaxAge: 60},
+		}),
+	)(okHandler)
+
+	tests := []struct {
+		origin      string
+		method      string
+		headers     string
+		status      int
+		maxAge      string
+		credentials string
+	}{
+		{"https://app.example.com", "PUT", "", http.StatusOK, "600", "true"},
+		{"https://app.example.com", "DELETE", "", http.StatusMethodNotAllowed, "", ""},
+		{"https://admin.example.com", "DELETE", "X-Admin", http.StatusOK, "60", ""},
+		{"https://admin.example.com", "PUT", "", http.StatusMethodNotAllowed, "", ""},
+		{"https://app.example.com", "GET", "X-Admin", http.StatusForbidden, "", ""},
+		{"https://www.example.com", "GET", "", http.StatusOK, "300", ""},
+		{"https://www.example.com", "PUT", "", http.StatusMethodNotAllowed, "", ""},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("OPTIONS", "http://www.example.com/")
+		r.Header.Set("Origin", tt.origin)
+		r.Header.Set(corsRequestMethodHeader, tt.method)
+		if tt.headers != "" {
+			r.Header.Set(corsRequestHeadersHeader, tt.headers)
+		}
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.status; got != want {
+			t.Fatalf("%s %s: bad status: got %v want %v", tt.origin, tt.method, got, want)
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		if got, want := rr.Header().Get(corsAllowOriginHeader), tt.origin; got != want {
+			t.Fatalf("%s %s: bad header: expected %q, got %q.", tt.origin, tt.method, want, got)
+		}
+		if got := rr.Header().Get(corsMaxAgeHeader); got != tt.maxAge {
+			t.Fatalf("%s %s: bad max age: expected %q, got %q.", tt.origin, tt.method, tt.maxAge, got)
+		}
+		if got := rr.Header().Get(corsAllowCredentialsHeader); got != tt.credentials {
+			t.Fatalf("%s %s: bad credentials: expected %q, got %q.", tt.origin, tt.method, tt.credentials, got)
+		}
+	}
+}
+
+func TestCORSDegenerateRequestedHeaders(t *testing.T) {
+	for _, value := range []string{"", " ", ",", ",,,", " , ,\t, "} {
+		for _, opts := range [][]CORSOption{nil, {FoldRequestedHeaders()}, {OptionStatusCode(http.StatusNoContent)}} {
+			r := newRequest("OPTIONS", "http://www.example.com/")
+			r.Header.Set("Origin", r.URL.String())
+			r.Header.Set(corsRequestMethodHeader, "PUT")
+			r.Header.Set(corsRequestHeadersHeader, value)
+			rr := httptest.NewRecorder()
+
+			handler := CORS(append([]CORSOption{AllowedMethods([]string{"PUT"})}, opts...)...)(okHandler)
+			handler.ServeHTTP(rr, r)
+
+			if got := rr.Code; got != http.StatusOK && got != http.StatusNoContent {
+				t.Fatalf("bad status for %q: got %v want 200 or 204", value, got)
+			}
+			if got := rr.Header().Get(corsAllowHeadersHeader); got != "" {
+				t.Fatalf("bad header for %q: expected no %s, got %q.", value, corsAllowHeadersHeader, got)
+			}
+		}
+	}
+}
+
+func TestCORSSafeMethodOrigins(t *testing.T) {
+	handler := CORS(
+		AllowedOrigins([]string{"https://app.example.com"}),
+		AllowedMethods([]string{"GET", "HEAD", "POST"}),
+		SafeMethodOrigins([]string{"https://*.example.org"}),
+	)(okHandler)
+
+	tests := []struct {
+		method          string
+		requestedMethod string
+		origin          string
+		allowOrigin     string
+	}{
+		{"GET", "", "https://docs.example.org", "https://docs.example.org"},
+		{"HEAD", "", "https://docs.example.org", "https://docs.example.org"},
+		{"POST", "", "https://docs.example.org", ""},
+		{"POST", "", "https://app.example.com", "https://app.example.com"},
+		{"OPTIONS", "GET", "https://docs.example.org", "https://docs.example.org"},
+		{"OPTIONS", "POST", "https://docs.example.org", ""},
+		{"OPTIONS", "POST", "https://app.example.com", "https://app.example.com"},
+	}
+
+	for _, tt := range tests {
+		r := newRequest(tt.method, "http://www.example.com/")
+		r.Header.Set("Origin", tt.origin)
+		if tt.requestedMethod != "" {
+			r.Header.Set(corsRequestMethodHeader, tt.requestedMethod)
+		}
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got := rr.Header().Get(corsAllowOriginHeader); got != tt.allowOrigin {
+			t.Fatalf("bad header for %s %s from %s: expected %q, got %q.", tt.method, tt.requestedMethod, tt.origin, tt.allowOrigin, got)
+		}
+		if tt.method == "OPTIONS" && tt.allowOrigin != "" && !varyContains(rr.Header()[corsVaryHeader], corsRequestMethodHeader) {
+			t.Fatalf("bad header for %s %s: expected Vary to list %s, got %q.", tt.method, tt.requestedMethod, corsRequestMethodHeader, rr.Header()[corsVaryHeader])
+		}
+	}
+}
+
+func TestCORSSafeMethodOriginsWildcardWithCredentials(t *testing.T) {
+	handler := CORS(
+		AllowedOrigins([]string{"https://app.example.com"}),
+		AllowedMethods([]string{"GET", "HEAD", "POST"}),
+		SafeMethodOrigins([]string{"*"}),
+		AllowCredentials(),
+	)(okHandler)
+
+	tests := []struct {
+		method          string
+		requestedMethod string
+		origin          string
+		allowOrigin     string
+		credentials     string
+	}{
+		{"GET", "", "https://evil.com", "*", ""},
+		{"HEAD", "", "https://evil.com", "*", ""},
+		{"OPTIONS", "GET", "https://evil.com", "*", ""},
+		{"POST", "", "https://evil.com", "", ""},
+		{"OPTIONS", "POST", "https://evil.com", "", ""},
+		{"GET", "", "https://app.example.com", "https://app.example.com", "true"},
+		{"POST", "", "https://app.example.com", "https://app.example.com", "true"},
+	}
+
+	for _, tt := range tests {
+		r := newRequest(tt.method, "http://www.example.com/")
+		r.Header.Set("Origin", tt.origin)
+		if tt.requestedMethod != "" {
+			r.Header.Set(corsRequestMethodHeader, tt.requestedMethod)
+		}
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got := rr.Header().Get(corsAllowOriginHeader); got != tt.allowOrigin {
+			t.Fatalf("bad header for %s %s from %s: expected %q, got %q.", tt.method, tt.requestedMethod, tt.origin, tt.allowOrigin, got)
+		}
+		if got := rr.Header().Get(corsAllowCredentialsHeader); got != tt.credentials {
+			t.Fatalf("bad header for %s %s from %s: expected %s %q, got %q.", tt.method, tt.requestedMethod, tt.origin, corsAllowCredentialsHeader, tt.credentials, got)
+		}
+	}
+}
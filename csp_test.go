@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestContentSecurityPolicyNonce(t *testing.T) {
+	var nonce string
+	handler := ContentSecurityPolicy("script-src 'nonce-{nonce}'; style-src 'nonce-{nonce}'")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce = CSPNonce(r.Context())
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newRequest("GET", "/"))
+
+	if nonce == "" {
+		t.Fatal("expected a nonce in the request context")
+	}
+	want := "script-src 'nonce-" + nonce + "'; style-src 'nonce-" + nonce + "'"
+	if got := rr.Header().Get(cspHeader); got != want {
+		t.Fatalf("bad header: expected %q, got %q.", want, got)
+	}
+
+	first := nonce
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest("GET", "/"))
+	if nonce == first {
+		t.Fatalf("nonce reused across requests: %q", nonce)
+	}
+}
+
+func TestContentSecurityPolicyWithoutNonce(t *testing.T) {
+	var nonce string
+	handler := ContentSecurityPolicy("default-src 'self'")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce = CSPNonce(r.Context())
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newRequest("GET", "/"))
+
+	if nonce != "" {
+		t.Fatalf("unexpected nonce %q", nonce)
+	}
+	if got, want := rr.Header().Get(cspHeader), "default-src 'self'"; got != want {
+		t.Fatalf("bad header: expected %q, got %q.", want, got)
+	}
+}
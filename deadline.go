@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultDeadlineHeader is the request header from which Deadline reads a
+// client-requested timeout.
+const DefaultDeadlineHeader = "X-Request-Timeout"
+
+// DeadlineOption provides a functional approach to configure the Deadline
+// middleware.
+type DeadlineOption func(*deadlineHandler)
+
+type deadlineHandler struct {
+	h       http.Handler
+	timeout time.Duration
+	header  string
+	logger  RecoveryHandlerLogger
+}
+
+// Deadline is HTTP middleware that sets a deadline on the request context,
+// timeout after the request arrives. Unlike http.TimeoutHandler it does not
+// write a response when the deadline passes: handlers are expected to honour
+// the context and respond as they see fit. A message is logged for requests
+// that are still being served when the deadline passes, including ones that
+// panic, so Deadline may be placed inside a RecoveryHandler.
+//
+// Clients may ask for a shorter deadline by sending an X-Request-Timeout
+// header holding either a number of seconds or a Go duration such as
+// "500ms". Longer values are ignored.
+func Deadline(timeout time.Duration, opts ...DeadlineOption) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		dh := &deadlineHandler{
+			h:       h,
+			timeout: timeout,
+			header:  DefaultDeadlineHeader,
+		}
+
+		for _, option := range opts {
+			option(dh)
+		}
+
+		return dh
+	}
+}
+
+// DeadlineHeader sets the request header from which a client-requested
+// timeout is read. An empty name disables client-requested timeouts.
+func DeadlineHeader(name string) DeadlineOption {
+	return func(dh *deadlineHandler) {
+		dh.header = name
+	}
+}
+
+// DeadlineLogger is a functional option to override the default logger used
+// to report exceeded deadlines.
+func DeadlineLogger(logger RecoveryHandlerLogger) DeadlineOption {
+	return func(dh *deadlineHandler) {
+		dh.logger = logger
+	}
+}
+
+func (dh *deadlineHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	timeout := dh.timeout
+	if dh.header != "" {
+		if requested, ok := parseRequestTimeout(r.Header.Get(dh.header)); ok && requested < timeout {
+			timeout = requested
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	defer func() {
+		if ctx.Err() == context.DeadlineExceeded {
+			dh.log("handlers: request deadline of", timeout, "exceeded for", r.Method, r.URL.Path)
+		}
+	}()
+
+	dh.h.ServeHTTP(w, r.WithContext(ctx))
+}
+
+func (dh *deadlineHandler) log(v ...interface{}) {
+	if dh.logger != nil {
+		dh.logger.Println(v...)
+	} else {
+		log.Println(v...)
+	}
+}
+
+// parseRequestTimeout parses a timeout given either as a number of seconds or
+// as a Go duration.
+func parseRequestTimeout(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds <= 0 {
+			return 0, false
+		}
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, false
+	}
+
+	return d, true
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeadlineSetsContextDeadline(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", time.Minute},
+		{"5", 5 * time.Second},
+		{"500ms", 500 * time.Millisecond},
+		{"2h", time.Minute},
+		{"invalid", time.Minute},
+	}
+
+	for _, tt := range tests {
+		var remaining time.Duration
+		handler := Deadline(time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			deadline, ok := r.Context().Deadline()
+			if !ok {
+				t.Fatal("expected a deadline on the request context")
+			}
+			remaining = time.Until(deadline)
+		}))
+
+		r := newRequest("GET", "/")
+		if tt.header != "" {
+			r.Header.Set(DefaultDeadlineHeader, tt.header)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+
+		if remaining > tt.want || remaining < tt.want-time.Second {
+			t.Fatalf("bad deadline for %q: got %v want about %v", tt.header, remaining, tt.want)
+		}
+	}
+}
+
+func TestDeadlineLogsWhenExceeded(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.New(&buf, "", 0)
+
+	handler := Deadline(10*time.Millisecond, DeadlineLogger(logger))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newRequest("GET", "/slow"))
+
+	if got, want := rr.Code, http.StatusAccepted; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+	if !strings.Contains(buf.String(), "/slow") {
+		t.Fatalf("Got log %#v, wanted substring %#v", buf.String(), "/slow")
+	}
+
+	buf.Reset()
+	Deadline(time.Minute, DeadlineLogger(logger))(okHandler).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "/fast"))
+	if buf.Len() != 0 {
+		t.Fatalf("unexpected log: %q", buf.String())
+	}
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DeprecationOption provides a functional approach to configure the
+// Deprecated middleware.
+type DeprecationOption func(*deprecationHandler)
+
+type deprecationHandler struct {
+	h      http.Handler
+	date   time.Time
+	sunset time.Time
+	link   string
+	warn   bool
+	logger RecoveryHandlerLogger
+}
+
+// Deprecated is HTTP middleware that marks the wrapped handler as deprecated
+// since date by adding a Deprecation header (RFC 9745) to every response, so
+// that clients can detect and migrate away from retired endpoints.
+//
+// Example:
+//
+//  since := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+//  r.Handle("/v1/users", handlers.Deprecated(since,
+//  	handlers.DeprecationSunset(since.AddDate(1, 0, 0)),
+//  	handlers.DeprecationLink("https://example.com/docs/migrate-to-v2"),
+//  )(usersV1))
+func Deprecated(date time.Time, opts ...DeprecationOption) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		dh := &deprecationHandler{
+			h:    h,
+			date: date,
+		}
+
+		for _, option := range opts {
+			option(dh)
+		}
+
+		return dh
+	}
+}
+
+// DeprecationSunset adds a Sunset header (RFC 8594) announcing when the
+// endpoint will stop responding.
+func DeprecationSunset(sunset time.Time) DeprecationOption {
+	return func(dh *deprecationHandler) {
+		dh.sunset = sunset
+	}
+}
+
+// DeprecationLink adds a Link header with relation type "deprecation"
+// pointing to documentation about the deprecation, such as a migration guide.
+func DeprecationLink(url string) DeprecationOption {
+	return func(dh *deprecationHandler) {
+		dh.link = url
+	}
+}
+
+// DeprecationWarning makes Deprecated log a warning for every request to the
+// deprecated endpoint, using logger, or the log package if logger is nil.
+func DeprecationWarning(logger RecoveryHandlerLogger) DeprecationOption {
+	return func(dh *deprecationHandler) {
+		dh.warn = true
+		dh.logger = logger
+	}
+}
+
+func (dh *deprecationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	header := w.Header()
+	header.Set("Deprecation", "@"+strconv.FormatInt(dh.date.Unix(), 10))
+	if !dh.sunset.IsZero() {
+		header.Set("Sunset", dh.sunset.UTC().Format(http.TimeFormat))
+	}
+	if dh.link != "" {
+		header.Add("Link", fmt.Sprintf("<%s>; rel=\"deprecation\"", dh.link))
+	}
+
+	if dh.warn {
+		message := fmt.Sprintf("handlers: deprecated endpoint %s %s requested by %s", r.Method, r.URL.Path, r.UserAgent())
+		if dh.logger != nil {
+			dh.logger.Println(message)
+		} else {
+			log.Println(message)
+		}
+	}
+
+	dh.h.ServeHTTP(w, r)
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeprecated(t *testing.T) {
+	since := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	handler := Deprecated(since,
+		DeprecationSunset(since.AddDate(1, 0, 0)),
+		DeprecationLink("https://example.com/docs/v2"),
+		DeprecationWarning(log.New(&buf, "", 0)),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Link", `</v2/users>; rel="successor-version"`)
+		w.Write([]byte("ok"))
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newRequest("GET", "http://www.example.com/v1/users"))
+
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+
+	header := rr.Header()
+	if got, want := header.Get("Deprecation"), "@1704067200"; got != want {
+		t.Fatalf("bad header: expected %q, got %q.", want, got)
+	}
+	if got, want := header.Get("Sunset"), "Wed, 01 Jan 2025 00:00:00 GMT"; got != want {
+		t.Fatalf("bad header: expected %q, got %q.", want, got)
+	}
+	links := strings.Join(header["Link"], ", ")
+	for _, want := range []string{`<https://example.com/docs/v2>; rel="deprecation"`, `</v2/users>; rel="successor-version"`} {
+		if !strings.Contains(links, want) {
+			t.Fatalf("bad header: expected Link %q in %q.", want, links)
+		}
+	}
+
+	if !strings.Contains(buf.String(), "deprecated endpoint GET /v1/users") {
+		t.Fatalf("Got log %#v, wanted substring %#v", buf.String(), "deprecated endpoint GET /v1/users")
+	}
+}
+
+func TestDeprecatedWithoutOptions(t *testing.T) {
+	since := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	handler := Deprecated(since)(okHandler)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newRequest("GET", "http://www.example.com/"))
+
+	if got, want := rr.Header().Get("Deprecation"), "@1704067200"; got != want {
+		t.Fatalf("bad header: expected %q, got %q.", want, got)
+	}
+	for _, name := range []string{"Sunset", "Link"} {
+		if got := rr.Header().Get(name); got != "" {
+			t.Fatalf("bad header: expected no %s, got %q.", name, got)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFramingGuard(t *testing.T) {
+	tests := []struct {
+		name             string
+		contentLength    []string
+		transferEncoding []string
+		parsedEncoding   []string
+		status           int
+	}{
+		{"content length only", []string{"5"}, nil, nil, http.StatusOK},
+		{"chunked only", nil, []string{"chunked"}, nil, http.StatusOK},
+		{"parsed chunked", nil, nil, []string{"chunked"}, http.StatusOK},
+		{"gzip then chunked", nil, []string{"gzip, chunked"}, nil, http.StatusOK},
+		{"no framing headers", nil, nil, nil, http.StatusOK},
+		{"conflicting headers", []string{"5"}, []string{"chunked"}, nil, http.StatusBadRequest},
+		{"conflicting parsed headers", []string{"5"}, nil, []string{"chunked"}, http.StatusBadRequest},
+		{"conflicting content lengths", []string{"5", "6"}, nil, nil, http.StatusBadRequest},
+		{"trailing whitespace", nil, []string{"chunked "}, nil, http.StatusBadRequest},
+		{"leading whitespace", nil, []string{" chunked"}, nil, http.StatusBadRequest},
+		{"unknown coding", nil, []string{"xchunked"}, nil, http.StatusBadRequest},
+		{"chunked not last", nil, []string{"chunked, gzip"}, nil, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("POST", "/")
+		if tt.contentLength != nil {
+			r.Header["Content-Length"] = tt.contentLength
+		}
+		if tt.transferEncoding != nil {
+			r.Header["Transfer-Encoding"] = tt.transferEncoding
+		}
+		r.TransferEncoding = tt.parsedEncoding
+		rr := httptest.NewRecorder()
+
+		FramingGuard(okHandler).ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.status; got != want {
+			t.Fatalf("bad status for %s: got %v want %v", tt.name, got, want)
+		}
+	}
+}
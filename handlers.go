@@ -7,10 +7,13 @@ package handlers
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"sort"
 	"strings"
+
+	"github.com/felixge/httpsnoop"
 )
 
 // MethodHandler is an http.Handler that dispatches to a handler whose key in the
@@ -70,6 +73,22 @@ func (l *responseLogger) Size() int {
 	return l.size
 }
 
+// finalStatus returns the status of the response once the handler has
+// returned, or panicked if completed is false. It must be used with a logger
+// whose status starts at zero. A handler that wrote no status leaves the
+// server to send 200 OK, unless it panicked before writing anything, in which
+// case the response is taken to be 500 Internal Server Error.
+func (l *responseLogger) finalStatus(completed bool) int {
+	if l.status != 0 {
+		return l.status
+	}
+	if !completed && l.size == 0 {
+		return http.StatusInternalServerError
+	}
+
+	return http.StatusOK
+}
+
 func (l *responseLogger) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	conn, rw, err := l.w.(http.Hijacker).Hijack()
 	if err == nil && l.status == 0 {
@@ -80,6 +99,50 @@ func (l *responseLogger) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	return conn, rw, err
 }
 
+// onWriteHeader wraps w so that fn is called exactly once with the response
+// status, just before the headers are written. fn may modify w.Header(). The
+// returned function must be called after the wrapped handler returns, so that
+// fn also runs when the handler writes nothing and the server sends the
+// headers itself.
+func onWriteHeader(w http.ResponseWriter, fn func(status int)) (http.ResponseWriter, func()) {
+	called := false
+	once := func(status int) {
+		if !called {
+			called = true
+			fn(status)
+		}
+	}
+
+	wrapped := httpsnoop.Wrap(w, httpsnoop.Hooks{
+		WriteHeader: func(next httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
+			return func(code int) {
+				once(code)
+				next(code)
+			}
+		},
+		Write: func(next httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+			return func(b []byte) (int, error) {
+				once(http.StatusOK)
+				return next(b)
+			}
+		},
+		ReadFrom: func(next httpsnoop.ReadFromFunc) httpsnoop.ReadFromFunc {
+			return func(src io.Reader) (int64, error) {
+				once(http.StatusOK)
+				return next(src)
+			}
+		},
+		Flush: func(next httpsnoop.FlushFunc) httpsnoop.FlushFunc {
+			return func() {
+				once(http.StatusOK)
+				next()
+			}
+		},
+	})
+
+	return wrapped, func() { once(http.StatusOK) }
+}
+
 // isContentType validates the Content-Type header matches the supplied
 // contentType. That is, its type and subtype match.
 func isContentType(h http.Header, contentType string) bool {
@@ -0,0 +1,36 @@
+package handlers
+
+import "net/http"
+
+// ResponseHeaderCase is HTTP middleware that sends the given response
+// headers with exactly the casing in names, such as "ETag" or
+// "WWW-Authenticate", rather than the canonical form Go uses, for clients
+// that compare header names case-sensitively. Headers are renamed just before
+// the response headers are written, so handlers may keep setting them with
+// w.Header().Set. Header names are otherwise case-insensitive, so this only
+// affects interoperability with such clients.
+//
+// Example:
+//
+//  http.ListenAndServe(":1123", handlers.ResponseHeaderCase([]string{"ETag"})(r))
+func ResponseHeaderCase(names []string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := w.Header()
+			ww, done := onWriteHeader(w, func(int) {
+				for _, name := range names {
+					canonical := http.CanonicalHeaderKey(name)
+					if canonical == name {
+						continue
+					}
+					if values, ok := header[canonical]; ok {
+						header[name] = append(header[name], values...)
+						delete(header, canonical)
+					}
+				}
+			})
+			h.ServeHTTP(ww, r)
+			done()
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseHeaderCase(t *testing.T) {
+	s := httptest.NewServer(ResponseHeaderCase([]string{"ETag", "WWW-Authenticate"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("X-Other", "1")
+		w.Write([]byte("ok"))
+	})))
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", s.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "GET / HTTP/1.1\r\nHost: example.com\r\nConnection: close\r\n\r\n")
+
+	var lines []string
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() && scanner.Text() != "" {
+		lines = append(lines, scanner.Text())
+	}
+	response := strings.Join(lines, "\n")
+
+	if !strings.Contains(response, "\nETag: \"v1\"") {
+		t.Fatalf("ETag not sent with exact casing:\n%s", response)
+	}
+	if strings.Contains(response, "\nEtag:") {
+		t.Fatalf("canonical Etag header still sent:\n%s", response)
+	}
+	if !strings.Contains(response, "\nX-Other: 1") {
+		t.Fatalf("other headers not sent:\n%s", response)
+	}
+}
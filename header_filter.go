@@ -0,0 +1,67 @@
+package handlers
+
+import "net/http"
+
+// HeaderFilterOption provides a functional approach to configure the
+// HeaderFilter middleware.
+type HeaderFilterOption func(*headerFilter)
+
+type headerFilter struct {
+	h      http.Handler
+	remove []string
+	set    http.Header
+}
+
+// HeaderFilter is HTTP middleware that rewrites the response headers set by
+// the wrapped handler just before they are sent to the client. It can remove
+// headers such as Server or X-Powered-By and add static headers.
+//
+// Example:
+//
+//  filter := handlers.HeaderFilter(
+//  	handlers.RemoveResponseHeaders("Server", "X-Powered-By"),
+//  	handlers.SetResponseHeader("X-Frame-Options", "DENY"),
+//  )
+//
+//  http.ListenAndServe(":1123", filter(r))
+func HeaderFilter(opts ...HeaderFilterOption) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		hf := &headerFilter{h: h, set: make(http.Header)}
+
+		for _, option := range opts {
+			option(hf)
+		}
+
+		return hf
+	}
+}
+
+// RemoveResponseHeaders removes the named headers from every response.
+func RemoveResponseHeaders(names ...string) HeaderFilterOption {
+	return func(hf *headerFilter) {
+		hf.remove = append(hf.remove, names...)
+	}
+}
+
+// SetResponseHeader sets a static header on every response, overriding any
+// value set by the wrapped handler.
+func SetResponseHeader(name, value string) HeaderFilterOption {
+	return func(hf *headerFilter) {
+		hf.set.Set(name, value)
+	}
+}
+
+func (hf *headerFilter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fw, done := onWriteHeader(w, func(int) {
+		h := w.Header()
+		for _, name := range hf.remove {
+			h.Del(name)
+		}
+		for name, values := range hf.set {
+			h[name] = values
+		}
+	})
+
+	hf.h.ServeHTTP(fw, r)
+	done()
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHeaderFilter(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "framework/1.0")
+		w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+		w.Header().Set("X-Kept", "yes")
+		w.Write([]byte(ok))
+	})
+
+	rr := httptest.NewRecorder()
+	HeaderFilter(
+		RemoveResponseHeaders("Server"),
+		SetResponseHeader("X-Frame-Options", "DENY"),
+		SetResponseHeader("X-Static", "1"),
+	)(handler).ServeHTTP(rr, newRequest("GET", "/"))
+
+	tests := map[string]string{
+		"Server":          "",
+		"X-Frame-Options": "DENY",
+		"X-Static":        "1",
+		"X-Kept":          "yes",
+	}
+	for name, want := range tests {
+		if got := rr.Header().Get(name); got != want {
+			t.Fatalf("bad header: expected %q to be %q, got %q.", name, want, got)
+		}
+	}
+}
+
+func TestHeaderFilterWithoutBody(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Powered-By", "framework")
+	})
+
+	rr := httptest.NewRecorder()
+	HeaderFilter(RemoveResponseHeaders("X-Powered-By"), SetResponseHeader("X-Static", "1"))(handler).ServeHTTP(rr, newRequest("GET", "/"))
+
+	if got := rr.Header().Get("X-Powered-By"); got != "" {
+		t.Fatalf("bad header: expected X-Powered-By to be removed, got %q.", got)
+	}
+	if got, want := rr.Header().Get("X-Static"), "1"; got != want {
+		t.Fatalf("bad header: expected X-Static to be %q, got %q.", want, got)
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/felixge/httpsnoop"
+)
+
+// HijackDeadline is HTTP middleware that applies idle deadlines to
+// connections taken over through http.Hijacker, such as WebSocket
+// connections, which the http.Server timeouts no longer cover. Once
+// hijacked, a read must complete within readTimeout of the previous read and
+// a write within writeTimeout of the previous write; each read or write
+// extends its deadline. This protects long-lived connections from clients
+// that trickle data or stop reading. A timeout of zero leaves that deadline
+// unset.
+//
+// Responses that are not hijacked are unaffected, and the ResponseWriter
+// keeps implementing the same interfaces.
+//
+// Example:
+//
+//  r.Handle("/ws", handlers.HijackDeadline(time.Minute, 10*time.Second)(wsHandler))
+func HijackDeadline(readTimeout, writeTimeout time.Duration) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(httpsnoop.Wrap(w, httpsnoop.Hooks{
+				Hijack: func(next httpsnoop.HijackFunc) httpsnoop.HijackFunc {
+					return func() (net.Conn, *bufio.ReadWriter, error) {
+						conn, rw, err := next()
+						if err != nil {
+							return conn, rw, err
+						}
+						return newDeadlineConn(conn, rw, readTimeout, writeTimeout)
+					}
+				},
+			}), r)
+		})
+	}
+}
+
+// deadlineConn refreshes the deadlines of a hijacked connection on every
+// read and write.
+type deadlineConn struct {
+	net.Conn
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+// newDeadlineConn wraps conn and returns a bufio.ReadWriter on top of the
+// wrapper, so that reads and writes through either refresh the deadlines.
+// Data already buffered by rw is carried over.
+func newDeadlineConn(conn net.Conn, rw *bufio.ReadWriter, readTimeout, writeTimeout time.Duration) (net.Conn, *bufio.ReadWriter, error) {
+	dc := &deadlineConn{Conn: conn, readTimeout: readTimeout, writeTimeout: writeTimeout}
+	if err := dc.refreshRead(); err != nil {
+		return nil, nil, err
+	}
+	if err := dc.refreshWrite(); err != nil {
+		return nil, nil, err
+	}
+
+	var reader io.Reader = dc
+	if rw != nil {
+		if err := rw.Writer.Flush(); err != nil {
+			return nil, nil, err
+		}
+		if n := rw.Reader.Buffered(); n > 0 {
+			buffered, _ := rw.Reader.Peek(n)
+			reader = io.MultiReader(bytes.NewReader(append([]byte(nil), buffered...)), dc)
+		}
+	}
+
+	return dc, bufio.NewReadWriter(bufio.NewReader(reader), bufio.NewWriter(dc)), nil
+}
+
+func (c *deadlineConn) Read(b []byte) (int, error) {
+	if err := c.refreshRead(); err != nil {
+		return 0, err
+	}
+
+	return c.Conn.Read(b)
+}
+
+func (c *deadlineConn) Write(b []byte) (int, error) {
+	if err := c.refreshWrite(); err != nil {
+		return 0, err
+	}
+
+	return c.Conn.Write(b)
+}
+
+func (c *deadlineConn) refreshRead() error {
+	if c.readTimeout <= 0 {
+		return nil
+	}
+
+	return c.Conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+}
+
+func (c *deadlineConn) refreshWrite() error {
+	if c.writeTimeout <= 0 {
+		return nil
+	}
+
+	return c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// deadlineRecordingConn records the deadlines set on it.
+type deadlineRecordingConn struct {
+	net.Conn
+	readDeadlines  []time.Time
+	writeDeadlines []time.Time
+}
+
+func (c *deadlineRecordingConn) SetReadDeadline(t time.Time) error {
+	c.readDeadlines = append(c.readDeadlines, t)
+	return nil
+}
+
+func (c *deadlineRecordingConn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadlines = append(c.writeDeadlines, t)
+	return nil
+}
+
+func (c *deadlineRecordingConn) Read(b []byte) (int, error)  { return 0, nil }
+func (c *deadlineRecordingConn) Write(b []byte) (int, error) { return len(b), nil }
+
+type hijackableRecorder struct {
+	*httptest.ResponseRecorder
+	conn net.Conn
+	rw   *bufio.ReadWriter
+}
+
+func (h *hijackableRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return h.conn, h.rw, nil
+}
+
+func TestHijackDeadline(t *testing.T) {
+	conn := &deadlineRecordingConn{}
+	// Simulate bytes the server read ahead before the handler hijacked the
+	// connection.
+	reader := bufio.NewReader(strings.NewReader("buffered"))
+	reader.Peek(len("buffered"))
+	w := &hijackableRecorder{
+		ResponseRecorder: httptest.NewRecorder(),
+		conn:             conn,
+		rw:               bufio.NewReadWriter(reader, bufio.NewWriter(conn)),
+	}
+
+	start := time.Now()
+	var buffered string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			t.Fatal("ResponseWriter lost http.Hijacker interface")
+		}
+		c, rw, err := hj.Hijack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		buffered, _ = rw.ReadString('d')
+		c.Write([]byte("hello"))
+	})
+
+	HijackDeadline(time.Minute, 10*time.Second)(handler).ServeHTTP(w, newRequest("GET", "/ws"))
+
+	if len(conn.readDeadlines) == 0 || len(conn.writeDeadlines) < 2 {
+		t.Fatalf("deadlines not refreshed: read %v, write %v", conn.readDeadlines, conn.writeDeadlines)
+	}
+	if d := conn.readDeadlines[0].Sub(start); d < time.Minute || d > time.Minute+time.Second {
+		t.Fatalf("bad read deadline: %v after start", d)
+	}
+	if d := conn.writeDeadlines[len(conn.writeDeadlines)-1].Sub(start); d < 10*time.Second || d > 11*time.Second {
+		t.Fatalf("bad write deadline: %v after start", d)
+	}
+	if got, want := buffered, "buffered"; got != want {
+		t.Fatalf("bad buffered data: got %q want %q", got, want)
+	}
+}
+
+func TestHijackDeadlineNotHijackable(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Hijacker); ok {
+			t.Error("ResponseWriter shouldn't implement http.Hijacker")
+		}
+	})
+
+	HijackDeadline(time.Minute, time.Minute)(handler).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "/"))
+}
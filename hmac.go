@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultHMACMaxBody = 1 << 20
+
+// HMACOption provides a functional approach to configure the HMACSignature
+// middleware.
+type HMACOption func(*hmacHandler)
+
+type hmacHandler struct {
+	h               http.Handler
+	secret          []byte
+	header          string
+	prefix          string
+	digest          func() hash.Hash
+	maxBody         int64
+	timestampHeader string
+	tolerance       time.Duration
+}
+
+// HMACSignature is HTTP middleware that verifies a hex-encoded HMAC of the
+// raw request body, such as the signature a webhook provider sends, in the
+// header named header. Requests with a missing or incorrect signature receive
+// 401 Unauthorized; bodies larger than the limit set by HMACMaxBody, 1MB by
+// default, receive 413 Request Entity Too Large. The body is buffered so that
+// the handler can still read it.
+//
+// SHA-256 is used unless HMACDigest selects another hash function.
+//
+// Example:
+//
+//  verify := handlers.HMACSignature(secret, "X-Hub-Signature-256", handlers.HMACSignaturePrefix("sha256="))
+//  http.ListenAndServe(":1123", verify(webhookHandler))
+func HMACSignature(secret []byte, header string, opts ...HMACOption) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		hh := &hmacHandler{
+			h:       h,
+			secret:  secret,
+			header:  header,
+			digest:  sha256.New,
+			maxBody: defaultHMACMaxBody,
+		}
+
+		for _, option := range opts {
+			option(hh)
+		}
+
+		return hh
+	}
+}
+
+// HMACDigest sets the hash function used to compute the HMAC, for example
+// sha1.New for providers that still sign with SHA-1.
+func HMACDigest(fn func() hash.Hash) HMACOption {
+	return func(hh *hmacHandler) {
+		hh.digest = fn
+	}
+}
+
+// HMACSignaturePrefix sets a prefix, such as "sha256=", that precedes the
+// hex-encoded signature in the header.
+func HMACSignaturePrefix(prefix string) HMACOption {
+	return func(hh *hmacHandler) {
+		hh.prefix = prefix
+	}
+}
+
+// HMACMaxBody sets the largest request body, in bytes, that is read to be
+// verified.
+func HMACMaxBody(n int64) HMACOption {
+	return func(hh *hmacHandler) {
+		hh.maxBody = n
+	}
+}
+
+// HMACTimestamp protects against replayed requests. The header named header
+// must hold the time the request was signed, in seconds since the Unix epoch,
+// within tolerance of the current time. The signature is then computed over
+// the timestamp, a period and the body, so the timestamp cannot be altered.
+func HMACTimestamp(header string, tolerance time.Duration) HMACOption {
+	return func(hh *hmacHandler) {
+		hh.timestampHeader = header
+		hh.tolerance = tolerance
+	}
+}
+
+func (hh *hmacHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body []byte
+	if r.Body != nil {
+		b, err := ioutil.ReadAll(io.LimitReader(r.Body, hh.maxBody+1))
+		r.Body.Close()
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		if int64(len(b)) > hh.maxBody {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+		body = b
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	if !hh.verify(r, body) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	hh.h.ServeHTTP(w, r)
+}
+
+// verify reports whether r carries a valid signature of body.
+func (hh *hmacHandler) verify(r *http.Request, body []byte) bool {
+	value := r.Header.Get(hh.header)
+	if !strings.HasPrefix(value, hh.prefix) {
+		return false
+	}
+	signature, err := hex.DecodeString(value[len(hh.prefix):])
+	if err != nil || len(signature) == 0 {
+		return false
+	}
+
+	mac := hmac.New(hh.digest, hh.secret)
+	if hh.timestampHeader != "" {
+		timestamp := r.Header.Get(hh.timestampHeader)
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return false
+		}
+		age := time.Since(time.Unix(seconds, 0))
+		if age > hh.tolerance || age < -hh.tolerance {
+			return false
+		}
+		io.WriteString(mac, timestamp+".")
+	}
+	mac.Write(body)
+
+	return hmac.Equal(mac.Sum(nil), signature)
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sign(digest func() hash.Hash, secret, payload string) string {
+	mac := hmac.New(digest, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestHMACSignature(t *testing.T) {
+	var got string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		got = string(b)
+	})
+	verify := HMACSignature([]byte("secret"), "X-Signature", HMACSignaturePrefix("sha256="))
+
+	tests := []struct {
+		body      string
+		signature string
+		status    int
+	}{
+		{`{"event":"push"}`, "sha256=" + sign(sha256.New, "secret", `{"event":"push"}`), http.StatusOK},
+		{`{"event":"delete"}`, "sha256=" + sign(sha256.New, "secret", `{"event":"push"}`), http.StatusUnauthorized},
+		{`{"event":"push"}`, "sha256=" + sign(sha256.New, "other", `{"event":"push"}`), http.StatusUnauthorized},
+		{`{"event":"push"}`, sign(sha256.New, "secret", `{"event":"push"}`), http.StatusUnauthorized},
+		{`{"event":"push"}`, "sha256=zz", http.StatusUnauthorized},
+		{`{"event":"push"}`, "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		got = ""
+		r, _ := http.NewRequest("POST", "/", strings.NewReader(tt.body))
+		r.Header.Set("X-Signature", tt.signature)
+		rr := httptest.NewRecorder()
+
+		verify(handler).ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.status; got != want {
+			t.Fatalf("bad status for %q: got %v want %v", tt.signature, got, want)
+		}
+		if tt.status == http.StatusOK && got != tt.body {
+			t.Fatalf("bad body: got %q want %q", got, tt.body)
+		}
+	}
+}
+
+func TestHMACSignatureDigest(t *testing.T) {
+	r, _ := http.NewRequest("POST", "/", strings.NewReader("payload"))
+	r.Header.Set("X-Signature", sign(sha1.New, "secret", "payload"))
+	rr := httptest.NewRecorder()
+
+	HMACSignature([]byte("secret"), "X-Signature", HMACDigest(sha1.New))(okHandler).ServeHTTP(rr, r)
+
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+}
+
+func TestHMACSignatureTimestamp(t *testing.T) {
+	verify := HMACSignature([]byte("secret"), "X-Signature", HMACTimestamp("X-Timestamp", 5*time.Minute))
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	expired := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+
+	tests := []struct {
+		timestamp string
+		signed    string
+		status    int
+	}{
+		{now, now, http.StatusOK},
+		{expired, expired, http.StatusUnauthorized},
+		{now, expired, http.StatusUnauthorized},
+		{"", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		r, _ := http.NewRequest("POST", "/", strings.NewReader("payload"))
+		r.Header.Set("X-Timestamp", tt.timestamp)
+		r.Header.Set("X-Signature", sign(sha256.New, "secret", tt.signed+".payload"))
+		rr := httptest.NewRecorder()
+
+		verify(okHandler).ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.status; got != want {
+			t.Fatalf("bad status for timestamp %q: got %v want %v", tt.timestamp, got, want)
+		}
+	}
+}
+
+func TestHMACSignatureMaxBody(t *testing.T) {
+	r, _ := http.NewRequest("POST", "/", strings.NewReader("payload"))
+	r.Header.Set("X-Signature", sign(sha256.New, "secret", "payload"))
+	rr := httptest.NewRecorder()
+
+	HMACSignature([]byte("secret"), "X-Signature", HMACMaxBody(4))(okHandler).ServeHTTP(rr, r)
+
+	if got, want := rr.Code, http.StatusRequestEntityTooLarge; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+)
+
+// hopByHopHeaders are the headers defined by RFC 7230, section 6.1 as
+// meaningful only for a single transport-level connection. "Trailers" is
+// the spelling used in the RFC; "Trailer" is the header actually sent.
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Trailers",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// HopByHopOption provides a functional approach to configure the
+// StripHopByHopHeaders middleware.
+type HopByHopOption func(*hopByHopHandler)
+
+type hopByHopHandler struct {
+	h               http.Handler
+	allowWebSockets bool
+}
+
+// StripHopByHopHeaders is HTTP middleware that removes hop-by-hop headers
+// from the request, along with any header named in its Connection header,
+// before passing it on. Place it in front of a handler that forwards the
+// request, such as a reverse proxy, so that these headers are not relayed to
+// the upstream server.
+//
+// Example:
+//
+//  proxy := httputil.NewSingleHostReverseProxy(upstream)
+//
+//  http.ListenAndServe(":1123", handlers.StripHopByHopHeaders(handlers.PreserveWebSocketUpgrade())(proxy))
+func StripHopByHopHeaders(opts ...HopByHopOption) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		hh := &hopByHopHandler{h: h}
+
+		for _, option := range opts {
+			option(hh)
+		}
+
+		return hh
+	}
+}
+
+// PreserveWebSocketUpgrade keeps the Upgrade and Connection headers of
+// WebSocket upgrade requests so that the upgrade can be proxied. Other
+// hop-by-hop headers are still removed.
+func PreserveWebSocketUpgrade() HopByHopOption {
+	return func(hh *hopByHopHandler) {
+		hh.allowWebSockets = true
+	}
+}
+
+func (hh *hopByHopHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	upgrade := hh.allowWebSockets && isWebSocketUpgrade(r)
+	upgradeValue := r.Header.Get("Upgrade")
+
+	for _, v := range r.Header["Connection"] {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				r.Header.Del(name)
+			}
+		}
+	}
+	for _, name := range hopByHopHeaders {
+		r.Header.Del(name)
+	}
+
+	if upgrade {
+		r.Header.Set("Connection", "Upgrade")
+		r.Header.Set("Upgrade", upgradeValue)
+	}
+
+	hh.h.ServeHTTP(w, r)
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStripHopByHopHeaders(t *testing.T) {
+	var got http.Header
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	})
+
+	r := newRequest("GET", "/")
+	r.Header.Set("Connection", "keep-alive, X-Hop")
+	r.Header.Set("Keep-Alive", "timeout=5")
+	r.Header.Set("Proxy-Authenticate", "Basic")
+	r.Header.Set("Te", "trailers")
+	r.Header.Set("Trailer", "X-Checksum")
+	r.Header.Set("Transfer-Encoding", "chunked")
+	r.Header.Set("Upgrade", "websocket")
+	r.Header.Set("X-Hop", "1")
+	r.Header.Set("X-End-To-End", "1")
+
+	StripHopByHopHeaders()(handler).ServeHTTP(httptest.NewRecorder(), r)
+
+	for _, name := range []string{"Connection", "Keep-Alive", "Proxy-Authenticate", "Te", "Trailer", "Transfer-Encoding", "Upgrade", "X-Hop"} {
+		if v := got.Get(name); v != "" {
+			t.Fatalf("hop-by-hop header %s not removed: got %q", name, v)
+		}
+	}
+	if got, want := got.Get("X-End-To-End"), "1"; got != want {
+		t.Fatalf("bad X-End-To-End: got %q want %q", got, want)
+	}
+}
+
+func TestStripHopByHopHeadersPreserveWebSocketUpgrade(t *testing.T) {
+	var got http.Header
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	})
+
+	r := newRequest("GET", "/")
+	r.Header.Set("Connection", "keep-alive, Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	r.Header.Set("Keep-Alive", "timeout=5")
+
+	StripHopByHopHeaders(PreserveWebSocketUpgrade())(handler).ServeHTTP(httptest.NewRecorder(), r)
+
+	if got, want := got.Get("Connection"), "Upgrade"; got != want {
+		t.Fatalf("bad Connection: got %q want %q", got, want)
+	}
+	if got, want := got.Get("Upgrade"), "websocket"; got != want {
+		t.Fatalf("bad Upgrade: got %q want %q", got, want)
+	}
+	if v := got.Get("Keep-Alive"); v != "" {
+		t.Fatalf("hop-by-hop header Keep-Alive not removed: got %q", v)
+	}
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	idempotencyKeyHeader   = "Idempotency-Key"
+	defaultIdempotencyTTL  = 24 * time.Hour
+	defaultIdempotencySize = 1 << 20
+)
+
+var defaultIdempotencyMethods = []string{"POST", "PATCH"}
+
+// IdempotentResponse is a response recorded by the Idempotency middleware.
+type IdempotentResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// IdempotencyStore records the responses to requests carrying an
+// Idempotency-Key header. Implementations backed by shared storage allow
+// retries to be deduplicated across several instances of a service.
+type IdempotencyStore interface {
+	// Begin is called at time now when a request for key arrives. If an
+	// unexpired response is stored for key it is returned. Otherwise Begin
+	// reserves key and reports whether it succeeded; it fails while another
+	// request holds the reservation.
+	Begin(key string, now time.Time) (resp *IdempotentResponse, reserved bool)
+	// Finish releases the reservation of key, storing resp until expires.
+	// resp is nil if the response should not be stored.
+	Finish(key string, resp *IdempotentResponse, expires time.Time)
+}
+
+// IdempotencyOption provides a functional approach to configure the
+// Idempotency middleware.
+type IdempotencyOption func(*idempotencyHandler)
+
+type idempotencyHandler struct {
+	h       http.Handler
+	store   IdempotencyStore
+	ttl     time.Duration
+	maxBody int
+	methods []string
+}
+
+// Idempotency is HTTP middleware that makes POST and PATCH requests carrying
+// an Idempotency-Key header safe to retry. The response to the first request
+// with a key is recorded in store and replayed for later requests with the
+// same key, method and path, for 24 hours by default. A request arriving
+// while an earlier one with the same key is still being served receives 409
+// Conflict.
+//
+// Responses are buffered in memory; those with a 5xx status or a body larger
+// than 1MB are passed on but not recorded.
+//
+// Example:
+//
+//  r := mux.NewRouter()
+//  r.HandleFunc("/payments", PaymentHandler)
+//
+//  store := handlers.NewMemoryIdempotencyStore()
+//  http.ListenAndServe(":1123", handlers.Idempotency(store)(r))
+func Idempotency(store IdempotencyStore, opts ...IdempotencyOption) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		ih := &idempotencyHandler{
+			h:       h,
+			store:   store,
+			ttl:     defaultIdempotencyTTL,
+			maxBody: defaultIdempotencySize,
+			methods: defaultIdempotencyMethods,
+		}
+
+		for _, option := range opts {
+			option(ih)
+		}
+
+		return ih
+	}
+}
+
+// IdempotencyTTL sets how long recorded responses are replayed.
+func IdempotencyTTL(d time.Duration) IdempotencyOption {
+	return func(ih *idempotencyHandler) {
+		ih.ttl = d
+	}
+}
+
+// IdempotencyMaxBody sets the size in bytes of the largest response body
+// that is recorded.
+func IdempotencyMaxBody(n int) IdempotencyOption {
+	return func(ih *idempotencyHandler) {
+		ih.maxBody = n
+	}
+}
+
+// IdempotencyMethods replaces the list of methods the middleware applies to.
+func IdempotencyMethods(methods []string) IdempotencyOption {
+	return func(ih *idempotencyHandler) {
+		ih.methods = methods
+	}
+}
+
+func (ih *idempotencyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	idempotencyKey := r.Header.Get(idempotencyKeyHeader)
+	if idempotencyKey == "" || !isMatch(r.Method, ih.methods) {
+		ih.h.ServeHTTP(w, r)
+		return
+	}
+
+	key := r.Method + " " + r.URL.Path + " " + idempotencyKey
+	resp, reserved := ih.store.Begin(key, time.Now())
+	if resp != nil {
+		writeIdempotentResponse(w, resp)
+		return
+	}
+	if !reserved {
+		http.Error(w, "A request with this Idempotency-Key is in progress", http.StatusConflict)
+		return
+	}
+
+	var stored *IdempotentResponse
+	defer func() {
+		ih.store.Finish(key, stored, time.Now().Add(ih.ttl))
+	}()
+
+	bw := newBufferedResponseWriter()
+	ih.h.ServeHTTP(bw, r)
+	bw.flush(w)
+
+	if bw.status < http.StatusInternalServerError && bw.body.Len() <= ih.maxBody {
+		stored = &IdempotentResponse{
+			StatusCode: bw.status,
+			Header:     bw.header.Clone(),
+			Body:       append([]byte(nil), bw.body.Bytes()...),
+		}
+	}
+}
+
+func writeIdempotentResponse(w http.ResponseWriter, resp *IdempotentResponse) {
+	for k, v := range resp.Header {
+		w.Header()[k] = append([]string(nil), v...)
+	}
+	w.WriteHeader(resp.StatusCode)
+	w.Write(resp.Body)
+}
+
+// memoryIdempotencySweepSize is the number of recorded responses above which
+// expired ones are removed from a memoryIdempotencyStore.
+const memoryIdempotencySweepSize = 10000
+
+type memoryIdempotencyStore struct {
+	mu        sync.Mutex
+	responses map[string]memoryIdempotentResponse
+	reserved  map[string]bool
+}
+
+type memoryIdempotentResponse struct {
+	resp    *IdempotentResponse
+	expires time.Time
+}
+
+// NewMemoryIdempotencyStore returns an IdempotencyStore that keeps responses
+// in memory.
+func NewMemoryIdempotencyStore() IdempotencyStore {
+	return &memoryIdempotencyStore{
+		responses: make(map[string]memoryIdempotentResponse),
+		reserved:  make(map[string]bool),
+	}
+}
+
+func (s *memoryIdempotencyStore) Begin(key string, now time.Time) (*IdempotentResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored, ok := s.responses[key]; ok {
+		if now.Before(stored.expires) {
+			return stored.resp, false
+		}
+		delete(s.responses, key)
+	}
+
+	if s.reserved[key] {
+		return nil, false
+	}
+
+	if len(s.responses) >= memoryIdempotencySweepSize {
+		s.sweep(now)
+	}
+
+	s.reserved[key] = true
+	return nil, true
+}
+
+func (s *memoryIdempotencyStore) Finish(key string, resp *IdempotentResponse, expires time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.reserved, key)
+	if resp != nil {
+		s.responses[key] = memoryIdempotentResponse{resp: resp, expires: expires}
+	}
+}
+
+// sweep removes expired responses.
+func (s *memoryIdempotencyStore) sweep(now time.Time) {
+	for key, stored := range s.responses {
+		if !now.Before(stored.expires) {
+			delete(s.responses, key)
+		}
+	}
+}
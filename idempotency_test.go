@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyReplay(t *testing.T) {
+	calls := 0
+	handler := Idempotency(NewMemoryIdempotencyStore())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("X-Call", strconv.Itoa(calls))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created"))
+	}))
+
+	for i := 0; i < 2; i++ {
+		r := newRequest("POST", "/payments")
+		r.Header.Set(idempotencyKeyHeader, "abc")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, r)
+
+		if got, want := rr.Code, http.StatusCreated; got != want {
+			t.Fatalf("bad status: got %v want %v", got, want)
+		}
+		if got, want := rr.Body.String(), "created"; got != want {
+			t.Fatalf("bad body: got %q want %q", got, want)
+		}
+		if got, want := rr.Header().Get("X-Call"), "1"; got != want {
+			t.Fatalf("bad replayed header: got %q want %q", got, want)
+		}
+	}
+
+	// Other keys and requests without a key are not deduplicated.
+	r := newRequest("POST", "/payments")
+	r.Header.Set(idempotencyKeyHeader, "def")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest("POST", "/payments"))
+
+	if got, want := calls, 3; got != want {
+		t.Fatalf("bad call count: got %v want %v", got, want)
+	}
+}
+
+func TestIdempotencyConcurrentDuplicate(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	handler := Idempotency(NewMemoryIdempotencyStore())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		w.Write([]byte(ok))
+	}))
+
+	newKeyedRequest := func() *http.Request {
+		r := newRequest("POST", "/payments")
+		r.Header.Set(idempotencyKeyHeader, "abc")
+		return r
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(httptest.NewRecorder(), newKeyedRequest())
+	}()
+	<-entered
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newKeyedRequest())
+	if got, want := rr.Code, http.StatusConflict; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+
+	close(release)
+	<-done
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, newKeyedRequest())
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+}
+
+func TestIdempotencyExpiry(t *testing.T) {
+	calls := 0
+	handler := Idempotency(NewMemoryIdempotencyStore(), IdempotencyTTL(10*time.Millisecond))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+
+	for i := 0; i < 2; i++ {
+		r := newRequest("POST", "/payments")
+		r.Header.Set(idempotencyKeyHeader, "abc")
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if got, want := calls, 2; got != want {
+		t.Fatalf("bad call count: got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// requireJSONMaxBody is the largest request body RequireJSON buffers to
+// validate.
+const requireJSONMaxBody = 1 << 20
+
+// RequireJSON wraps and returns a http.Handler, validating that the request
+// has an application/json content type. It writes a HTTP 400 error
+// describing the problem if that fails. When validateBody is true, the body
+// must also be well-formed JSON or a HTTP 400 error describing the problem is
+// written; the body is buffered so the handler can still read it. Bodies
+// larger than 1MB are not buffered and receive a HTTP 413 error.
+//
+// Like ContentTypeHandler, only PUT, POST, and PATCH requests are considered.
+func RequireJSON(h http.Handler, validateBody bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !(r.Method == "PUT" || r.Method == "POST" || r.Method == "PATCH") {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if !isContentType(r.Header, "application/json") {
+			http.Error(w, fmt.Sprintf("Unsupported content type %q; expected %q", r.Header.Get("Content-Type"), "application/json"), http.StatusBadRequest)
+			return
+		}
+
+		if validateBody && r.Body != nil {
+			body, err := ioutil.ReadAll(io.LimitReader(r.Body, requireJSONMaxBody+1))
+			r.Body.Close()
+			if err != nil {
+				http.Error(w, "Error reading request body", http.StatusBadRequest)
+				return
+			}
+			if len(body) > requireJSONMaxBody {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			var raw json.RawMessage
+			if err := json.Unmarshal(body, &raw); err != nil {
+				http.Error(w, fmt.Sprintf("Invalid JSON request body: %v", err), http.StatusBadRequest)
+				return
+			}
+
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
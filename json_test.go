@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequireJSON(t *testing.T) {
+	var received string
+	handler := RequireJSON(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received = string(body)
+	}), true)
+
+	tests := []struct {
+		contentType string
+		body        string
+		code        int
+	}{
+		{"", `{"a":1}`, http.StatusBadRequest},
+		{"text/plain", `{"a":1}`, http.StatusBadRequest},
+		{"application/json", `{"a":`, http.StatusBadRequest},
+		{"application/json", `{"a":1} trailing`, http.StatusBadRequest},
+		{"application/json; charset=utf-8", `{"a":1}`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		received = ""
+		r, _ := http.NewRequest("POST", "/", strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			r.Header.Set("Content-Type", tt.contentType)
+		}
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.code; got != want {
+			t.Fatalf("bad status for %q %q: got %v want %v", tt.contentType, tt.body, got, want)
+		}
+		if tt.code == http.StatusOK && received != tt.body {
+			t.Fatalf("bad body: got %q want %q", received, tt.body)
+		}
+		wantBody := "Invalid JSON"
+		if !strings.HasPrefix(tt.contentType, "application/json") {
+			wantBody = "Unsupported content type"
+		}
+		if tt.code == http.StatusBadRequest && !strings.Contains(rr.Body.String(), wantBody) {
+			t.Fatalf("bad error body: got %q", rr.Body.String())
+		}
+	}
+}
+
+func TestRequireJSONBodyTooLarge(t *testing.T) {
+	handler := RequireJSON(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("oversized body must not reach the handler")
+	}), true)
+
+	body := `"` + strings.Repeat("a", requireJSONMaxBody) + `"`
+	r, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, r)
+
+	if got, want := rr.Code, http.StatusRequestEntityTooLarge; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+}
+
+func TestRequireJSONIgnoresGet(t *testing.T) {
+	rr := httptest.NewRecorder()
+	RequireJSON(okHandler, true).ServeHTTP(rr, newRequest("GET", "/"))
+
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type jwtClaimsContextKey struct{}
+
+var errJWTSignature = errors.New("handlers: invalid JWT signature")
+
+// JWTVerifier verifies the signature of a JSON Web Token. Implementations
+// may select a key by the "kid" header, for example from a JWKS document,
+// and must reject algorithms they do not expect.
+type JWTVerifier interface {
+	// VerifySignature returns an error unless signature is a valid signature
+	// of signingInput, the encoded header and payload of the token, for the
+	// algorithm alg and key ID kid from the token header.
+	VerifySignature(alg, kid string, signingInput, signature []byte) error
+}
+
+// JWTVerifierFunc adapts a function to the JWTVerifier interface.
+type JWTVerifierFunc func(alg, kid string, signingInput, signature []byte) error
+
+// VerifySignature calls f(alg, kid, signingInput, signature).
+func (f JWTVerifierFunc) VerifySignature(alg, kid string, signingInput, signature []byte) error {
+	return f(alg, kid, signingInput, signature)
+}
+
+// HS256Verifier returns a JWTVerifier that accepts only tokens signed with
+// HMAC-SHA256 using secret.
+func HS256Verifier(secret []byte) JWTVerifier {
+	return JWTVerifierFunc(func(alg, kid string, signingInput, signature []byte) error {
+		if alg != "HS256" {
+			return errJWTSignature
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signingInput)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return errJWTSignature
+		}
+		return nil
+	})
+}
+
+// JWTClaims holds the claims of a verified JSON Web Token. Numbers are
+// decoded as json.Number.
+type JWTClaims map[string]interface{}
+
+// Subject returns the "sub" claim, or an empty string if there is none.
+func (c JWTClaims) Subject() string {
+	sub, _ := c["sub"].(string)
+	return sub
+}
+
+// JWTOption provides a functional approach to configure the JWTAuth
+// middleware.
+type JWTOption func(*jwtHandler)
+
+type jwtHandler struct {
+	h              http.Handler
+	verifier       JWTVerifier
+	audience       string
+	leeway         time.Duration
+	allowAnonymous bool
+}
+
+// JWTAuth is HTTP middleware that authenticates requests with a JSON Web
+// Token sent as a Bearer token in the Authorization header. The signature is
+// checked by verifier, and the "exp" and "nbf" claims, and the "aud" claim if
+// JWTAudience is set, are validated. The claims of a valid token are
+// available to handlers through JWTClaimsFromContext. Requests with a
+// missing or invalid token receive 401 Unauthorized.
+//
+// Example:
+//
+//  auth := handlers.JWTAuth(handlers.HS256Verifier(secret), handlers.JWTAudience("api"))
+//  r.HandleFunc("/me", func(w http.ResponseWriter, r *http.Request) {
+//  	claims, _ := handlers.JWTClaimsFromContext(r.Context())
+//  	fmt.Fprintln(w, claims.Subject())
+//  })
+//  http.ListenAndServe(":1123", auth(r))
+func JWTAuth(verifier JWTVerifier, opts ...JWTOption) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		jh := &jwtHandler{
+			h:        h,
+			verifier: verifier,
+		}
+
+		for _, option := range opts {
+			option(jh)
+		}
+
+		return jh
+	}
+}
+
+// JWTAudience requires the "aud" claim of tokens to contain audience.
+func JWTAudience(audience string) JWTOption {
+	return func(jh *jwtHandler) {
+		jh.audience = audience
+	}
+}
+
+// JWTLeeway allows for clock skew of up to d when validating the "exp" and
+// "nbf" claims.
+func JWTLeeway(d time.Duration) JWTOption {
+	return func(jh *jwtHandler) {
+		jh.leeway = d
+	}
+}
+
+// JWTAllowAnonymous serves requests without an Authorization header, with no
+// claims in their context. Requests with an invalid token are still rejected.
+func JWTAllowAnonymous() JWTOption {
+	return func(jh *jwtHandler) {
+		jh.allowAnonymous = true
+	}
+}
+
+// JWTClaimsFromContext returns the claims stored by JWTAuth for the request
+// whose context is ctx. The boolean reports whether there are any.
+func JWTClaimsFromContext(ctx context.Context) (JWTClaims, bool) {
+	claims, ok := ctx.Value(jwtClaimsContextKey{}).(JWTClaims)
+	return claims, ok
+}
+
+func (jh *jwtHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	authorization := r.Header.Get("Authorization")
+	if authorization == "" && jh.allowAnonymous {
+		jh.h.ServeHTTP(w, r)
+		return
+	}
+
+	const prefix = "bearer "
+	if len(authorization) <= len(prefix) || !strings.EqualFold(authorization[:len(prefix)], prefix) {
+		jh.unauthorized(w, "")
+		return
+	}
+
+	claims, err := jh.parse(strings.TrimSpace(authorization[len(prefix):]), time.Now())
+	if err != nil {
+		jh.unauthorized(w, "invalid_token")
+		return
+	}
+
+	jh.h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), jwtClaimsContextKey{}, claims)))
+}
+
+func (jh *jwtHandler) unauthorized(w http.ResponseWriter, errorCode string) {
+	challenge := "Bearer"
+	if errorCode != "" {
+		challenge += ` error="` + errorCode + `"`
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+// parse verifies token and validates its claims at time now.
+func (jh *jwtHandler) parse(token string, now time.Time) (JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("handlers: malformed JWT")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	if err := jh.verifier.VerifySignature(header.Alg, header.Kid, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	var claims JWTClaims
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+
+	exp, ok, err := claims.time("exp")
+	if err != nil {
+		return nil, err
+	}
+	if ok && !now.Before(exp.Add(jh.leeway)) {
+		return nil, errors.New("handlers: JWT expired")
+	}
+	nbf, ok, err := claims.time("nbf")
+	if err != nil {
+		return nil, err
+	}
+	if ok && now.Add(jh.leeway).Before(nbf) {
+		return nil, errors.New("handlers: JWT not yet valid")
+	}
+	if jh.audience != "" && !claims.hasAudience(jh.audience) {
+		return nil, errors.New("handlers: JWT audience mismatch")
+	}
+
+	return claims, nil
+}
+
+// decodeJWTSegment decodes the base64url-encoded JSON segment s into v.
+func decodeJWTSegment(s string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// time returns the NumericDate claim name as a time, and whether the claim
+// is present. A claim that is present but not a number, or out of the range
+// of time.Time, is an error.
+func (c JWTClaims) time(name string) (time.Time, bool, error) {
+	v, ok := c[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("handlers: JWT %s claim is not a number", name)
+	}
+	seconds, err := n.Float64()
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return time.Time{}, false, fmt.Errorf("handlers: JWT %s claim is not a number", name)
+	}
+	if seconds < math.MinInt64 || seconds >= math.MaxInt64 {
+		return time.Time{}, false, fmt.Errorf("handlers: JWT %s claim is out of range", name)
+	}
+
+	sec, frac := math.Modf(seconds)
+	return time.Unix(int64(sec), int64(frac*1e9)), true, nil
+}
+
+// hasAudience reports whether the "aud" claim, a string or an array of
+// strings, contains audience.
+func (c JWTClaims) hasAudience(audience string) bool {
+	switch aud := c["aud"].(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, v := range aud {
+			if s, ok := v.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+
+	return false
+}
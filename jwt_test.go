@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newJWT(t *testing.T, secret string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestJWTAuth(t *testing.T) {
+	var subject string
+	handler := JWTAuth(HS256Verifier([]byte("secret")), JWTAudience("api"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := JWTClaimsFromContext(r.Context())
+		subject = claims.Subject()
+	}))
+
+	now := time.Now().Unix()
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+		subject       string
+	}{
+		{"valid", "Bearer " + newJWT(t, "secret", map[string]interface{}{"sub": "alice", "aud": "api", "exp": now + 60}), http.StatusOK, "alice"},
+		{"audience list", "bearer " + newJWT(t, "secret", map[string]interface{}{"sub": "bob", "aud": []string{"web", "api"}}), http.StatusOK, "bob"},
+		{"expired", "Bearer " + newJWT(t, "secret", map[string]interface{}{"sub": "alice", "aud": "api", "exp": now - 60}), http.StatusUnauthorized, ""},
+		{"not yet valid", "Bearer " + newJWT(t, "secret", map[string]interface{}{"sub": "alice", "aud": "api", "nbf": now + 60}), http.StatusUnauthorized, ""},
+		{"far-future exp", "Bearer " + newJWT(t, "secret", map[string]interface{}{"sub": "alice", "aud": "api", "exp": 1e12}), http.StatusOK, "alice"},
+		{"huge exp", "Bearer " + newJWT(t, "secret", map[string]interface{}{"sub": "alice", "aud": "api", "exp": 1e19}), http.StatusUnauthorized, ""},
+		{"huge nbf", "Bearer " + newJWT(t, "secret", map[string]interface{}{"sub": "alice", "aud": "api", "nbf": 1e15}), http.StatusUnauthorized, ""},
+		{"out of range nbf", "Bearer " + newJWT(t, "secret", map[string]interface{}{"sub": "alice", "aud": "api", "nbf": 1e19}), http.StatusUnauthorized, ""},
+		{"non-numeric exp", "Bearer " + newJWT(t, "secret", map[string]interface{}{"sub": "alice", "aud": "api", "exp": "never"}), http.StatusUnauthorized, ""},
+		{"non-numeric nbf", "Bearer " + newJWT(t, "secret", map[string]interface{}{"sub": "alice", "aud": "api", "nbf": true}), http.StatusUnauthorized, ""},
+		{"wrong audience", "Bearer " + newJWT(t, "secret", map[string]interface{}{"sub": "alice", "aud": "web"}), http.StatusUnauthorized, ""},
+		{"bad signature", "Bearer " + newJWT(t, "other", map[string]interface{}{"sub": "alice", "aud": "api"}), http.StatusUnauthorized, ""},
+		{"malformed", "Bearer abc", http.StatusUnauthorized, ""},
+		{"missing", "", http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		subject = ""
+		r := newRequest("GET", "/")
+		if tt.authorization != "" {
+			r.Header.Set("Authorization", tt.authorization)
+		}
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.status; got != want {
+			t.Fatalf("%s: bad status: got %v want %v", tt.name, got, want)
+		}
+		if got, want := subject, tt.subject; got != want {
+			t.Fatalf("%s: bad subject: got %q want %q", tt.name, got, want)
+		}
+		if tt.status == http.StatusUnauthorized && rr.Header().Get("WWW-Authenticate") == "" {
+			t.Fatalf("%s: missing WWW-Authenticate challenge", tt.name)
+		}
+	}
+}
+
+func TestJWTAuthAllowAnonymous(t *testing.T) {
+	var authenticated bool
+	handler := JWTAuth(HS256Verifier([]byte("secret")), JWTAllowAnonymous())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, authenticated = JWTClaimsFromContext(r.Context())
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newRequest("GET", "/"))
+
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+	if authenticated {
+		t.Fatal("unexpected claims for anonymous request")
+	}
+
+	r := newRequest("GET", "/")
+	r.Header.Set("Authorization", "Bearer abc")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+
+	if got, want := rr.Code, http.StatusUnauthorized; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+}
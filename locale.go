@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+type localeContextKey struct{}
+
+// LocaleHandler is HTTP middleware that negotiates the response language.
+// It picks the supported locale that best matches the request's
+// Accept-Language header, honouring q-values, and stores it in the request
+// context where handlers can read it with Locale. When nothing matches,
+// fallback is used. If setContentLanguage is true, the Content-Language
+// response header is set to the negotiated locale.
+//
+// A language range matches more specific supported locales and vice versa,
+// so "en" matches a supported "en-US" and "en-GB" matches a supported "en".
+//
+// Example:
+//
+//  locale := handlers.LocaleHandler([]string{"en", "fr", "de"}, "en", true)
+//
+//  r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+//  	fmt.Fprint(w, greetings[handlers.Locale(r.Context())])
+//  })
+//  http.ListenAndServe(":1123", locale(r))
+func LocaleHandler(supported []string, fallback string, setContentLanguage bool) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			locale := negotiateLocale(r.Header.Get("Accept-Language"), supported)
+			if locale == "" {
+				locale = fallback
+			}
+
+			if setContentLanguage && locale != "" {
+				w.Header().Set("Content-Language", locale)
+			}
+
+			h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), localeContextKey{}, locale)))
+		})
+	}
+}
+
+// Locale returns the locale negotiated by LocaleHandler for the request
+// whose context is ctx, or an empty string if there is none.
+func Locale(ctx context.Context) string {
+	locale, _ := ctx.Value(localeContextKey{}).(string)
+	return locale
+}
+
+func negotiateLocale(acceptLanguage string, supported []string) string {
+	for _, accepted := range parseQualityList(acceptLanguage) {
+		if accepted.value == "*" {
+			if len(supported) > 0 {
+				return supported[0]
+			}
+			continue
+		}
+
+		if locale := matchLocale(accepted.value, supported); locale != "" {
+			return locale
+		}
+	}
+
+	return ""
+}
+
+func matchLocale(tag string, supported []string) string {
+	for _, s := range supported {
+		if strings.EqualFold(s, tag) {
+			return s
+		}
+	}
+
+	for _, s := range supported {
+		if len(s) > len(tag) && s[len(tag)] == '-' && strings.EqualFold(s[:len(tag)], tag) {
+			return s
+		}
+	}
+
+	if i := strings.LastIndex(tag, "-"); i != -1 {
+		return matchLocale(tag[:i], supported)
+	}
+
+	return ""
+}
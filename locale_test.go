@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLocaleHandler(t *testing.T) {
+	tests := []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{"", "en"},
+		{"fr", "fr"},
+		{"de;q=0.5, fr;q=0.8", "fr"},
+		{"es, de;q=0.1", "de"},
+		{"es, *;q=0.5", "en-US"},
+		{"es, ja", "en"},
+		{"fr-CA", "fr"},
+		{"EN", "en-US"},
+		{"fr;q=0, de", "de"},
+	}
+
+	for _, tt := range tests {
+		var locale string
+		handler := LocaleHandler([]string{"en-US", "fr", "de"}, "en", true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			locale = Locale(r.Context())
+		}))
+
+		r := newRequest("GET", "/")
+		if tt.acceptLanguage != "" {
+			r.Header.Set("Accept-Language", tt.acceptLanguage)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, r)
+
+		if locale != tt.want {
+			t.Fatalf("bad locale for %q: got %q want %q", tt.acceptLanguage, locale, tt.want)
+		}
+		if got := rr.Header().Get("Content-Language"); got != tt.want {
+			t.Fatalf("bad Content-Language for %q: got %q want %q", tt.acceptLanguage, got, tt.want)
+		}
+	}
+}
@@ -37,9 +37,53 @@ type loggingHandler struct {
 	writer    io.Writer
 	handler   http.Handler
 	formatter LogFormatter
+	skip      []func(r *http.Request) bool
+}
+
+// LoggingOption provides a functional approach to configure the logging
+// handlers.
+type LoggingOption func(*loggingHandler)
+
+// LogSkipPaths is a functional option that disables logging of requests for
+// the given URL paths, such as health checks. Paths must match exactly.
+func LogSkipPaths(paths ...string) LoggingOption {
+	skipped := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		skipped[p] = true
+	}
+
+	return LogSkipFunc(func(r *http.Request) bool {
+		return skipped[r.URL.Path]
+	})
+}
+
+// LogSkipFunc is a functional option that disables logging of requests for
+// which fn returns true. fn is called before the request is served and
+// should be cheap.
+func LogSkipFunc(fn func(r *http.Request) bool) LoggingOption {
+	return func(h *loggingHandler) {
+		h.skip = append(h.skip, fn)
+	}
+}
+
+func newLoggingHandler(out io.Writer, h http.Handler, f LogFormatter, opts []LoggingOption) http.Handler {
+	lh := loggingHandler{writer: out, handler: h, formatter: f}
+
+	for _, option := range opts {
+		option(&lh)
+	}
+
+	return lh
 }
 
 func (h loggingHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	for _, skip := range h.skip {
+		if skip(req) {
+			h.handler.ServeHTTP(w, req)
+			return
+		}
+	}
+
 	t := time.Now()
 	logger, w := makeLogger(w)
 	url := *req.URL
@@ -213,8 +257,8 @@ func writeCombinedLog(writer io.Writer, params LogFormatterParams) {
 // See http://httpd.apache.org/docs/2.2/logs.html#combined for a description of this format.
 //
 // LoggingHandler always sets the ident field of the log to -
-func CombinedLoggingHandler(out io.Writer, h http.Handler) http.Handler {
-	return loggingHandler{out, h, writeCombinedLog}
+func CombinedLoggingHandler(out io.Writer, h http.Handler, opts ...LoggingOption) http.Handler {
+	return newLoggingHandler(out, h, writeCombinedLog, opts)
 }
 
 // LoggingHandler return a http.Handler that wraps h and logs requests to out in
@@ -233,12 +277,14 @@ func CombinedLoggingHandler(out io.Writer, h http.Handler) http.Handler {
 //  loggedRouter := handlers.LoggingHandler(os.Stdout, r)
 //  http.ListenAndServe(":1123", loggedRouter)
 //
-func LoggingHandler(out io.Writer, h http.Handler) http.Handler {
-	return loggingHandler{out, h, writeLog}
+// Requests for noisy endpoints can be left out of the log with LogSkipPaths
+// or LogSkipFunc.
+func LoggingHandler(out io.Writer, h http.Handler, opts ...LoggingOption) http.Handler {
+	return newLoggingHandler(out, h, writeLog, opts)
 }
 
 // CustomLoggingHandler provides a way to supply a custom log formatter
 // while taking advantage of the mechanisms in this package
-func CustomLoggingHandler(out io.Writer, h http.Handler, f LogFormatter) http.Handler {
-	return loggingHandler{out, h, f}
+func CustomLoggingHandler(out io.Writer, h http.Handler, f LogFormatter, opts ...LoggingOption) http.Handler {
+	return newLoggingHandler(out, h, f, opts)
 }
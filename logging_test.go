@@ -127,6 +127,34 @@ func TestLogPathRewrites(t *testing.T) {
 	}
 }
 
+func TestLogSkip(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := LoggingHandler(&buf, okHandler,
+		LogSkipPaths("/healthz", "/metrics"),
+		LogSkipFunc(func(r *http.Request) bool { return r.Header.Get("X-Probe") != "" }),
+	)
+
+	rr := httptest.NewRecorder()
+	logger.ServeHTTP(rr, newRequest("GET", "/healthz"))
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+
+	probe := newRequest("GET", "/")
+	probe.Header.Set("X-Probe", "1")
+	logger.ServeHTTP(httptest.NewRecorder(), probe)
+
+	if buf.Len() != 0 {
+		t.Fatalf("expected no log for skipped requests, got %q", buf.String())
+	}
+
+	logger.ServeHTTP(httptest.NewRecorder(), newRequest("GET", "/users"))
+	if !strings.Contains(buf.String(), "GET /users HTTP") {
+		t.Fatalf("Got log %#v, wanted substring %#v", buf.String(), "GET /users HTTP")
+	}
+}
+
 func BenchmarkWriteLog(b *testing.B) {
 	loc, err := time.LoadLocation("Europe/Warsaw")
 	if err != nil {
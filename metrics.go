@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+)
+
+// MetricsObserver receives a measurement for every request served by the
+// Metrics middleware.
+type MetricsObserver interface {
+	ObserveRequest(method, path string, status int, d time.Duration)
+}
+
+// MetricsOption provides a functional approach to configure the Metrics
+// middleware.
+type MetricsOption func(*metricsHandler)
+
+type metricsHandler struct {
+	h        http.Handler
+	observer MetricsObserver
+	pathFunc func(r *http.Request) string
+}
+
+// Metrics is HTTP middleware that reports the method, path, status and
+// duration of every request to observer. Requests whose handler panics are
+// reported with a 500 status, unless a status was already written, before the
+// panic continues up the stack.
+//
+// By default the path reported is the raw URL path, which may produce an
+// unbounded number of distinct values. Use MetricsRouteTemplate to report a
+// route template such as "/users/{id}" instead.
+//
+// Example:
+//
+//  r := mux.NewRouter()
+//  r.HandleFunc("/users/{id}", UserHandler)
+//
+//  http.ListenAndServe(":1123", handlers.Metrics(observer)(r))
+func Metrics(observer MetricsObserver, opts ...MetricsOption) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		mh := &metricsHandler{
+			h:        h,
+			observer: observer,
+			pathFunc: func(r *http.Request) string { return r.URL.Path },
+		}
+
+		for _, option := range opts {
+			option(mh)
+		}
+
+		return mh
+	}
+}
+
+// MetricsRouteTemplate sets the function used to derive the reported path
+// from a request. It should return a low-cardinality value, such as the
+// template of the matched route.
+func MetricsRouteTemplate(fn func(r *http.Request) string) MetricsOption {
+	return func(mh *metricsHandler) {
+		mh.pathFunc = fn
+	}
+}
+
+func (mh *metricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t := time.Now()
+	logger, w := makeLogger(w)
+	logger.status = 0
+
+	completed := false
+	defer func() {
+		mh.observer.ObserveRequest(r.Method, mh.pathFunc(r), logger.finalStatus(completed), time.Since(t))
+	}()
+
+	mh.h.ServeHTTP(w, r)
+	completed = true
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type observation struct {
+	method string
+	path   string
+	status int
+	d      time.Duration
+}
+
+type recordingObserver struct {
+	observations []observation
+}
+
+func (o *recordingObserver) ObserveRequest(method, path string, status int, d time.Duration) {
+	o.observations = append(o.observations, observation{method, path, status, d})
+}
+
+func TestMetrics(t *testing.T) {
+	observer := &recordingObserver{}
+	template := func(r *http.Request) string { return "/users/{id}" }
+
+	handler := Metrics(observer, MetricsRouteTemplate(template))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest("POST", "/users/42"))
+
+	Metrics(observer)(okHandler).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "/raw"))
+
+	if got, want := len(observer.observations), 2; got != want {
+		t.Fatalf("bad observation count: got %v want %v", got, want)
+	}
+
+	o := observer.observations[0]
+	if o.method != "POST" || o.path != "/users/{id}" || o.status != http.StatusCreated || o.d < time.Millisecond {
+		t.Fatalf("bad observation: %+v", o)
+	}
+
+	o = observer.observations[1]
+	if o.method != "GET" || o.path != "/raw" || o.status != http.StatusOK {
+		t.Fatalf("bad observation: %+v", o)
+	}
+}
+
+func TestMetricsPanic(t *testing.T) {
+	observer := &recordingObserver{}
+	handler := Metrics(observer)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("Unexpected error!")
+	}))
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected the panic to propagate")
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), newRequest("GET", "/panic"))
+	}()
+
+	if got, want := len(observer.observations), 1; got != want {
+		t.Fatalf("bad observation count: got %v want %v", got, want)
+	}
+	if got, want := observer.observations[0].status, http.StatusInternalServerError; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// qualityValue is an entry of a header such as Accept-Language or
+// Accept-Charset, together with its "q" weight.
+type qualityValue struct {
+	value   string
+	quality float64
+}
+
+// parseQualityList parses a comma-separated list of values with optional
+// ";q=" weights, as used by the Accept-* request headers. Entries with a
+// weight of zero, which mark a value as not acceptable, are dropped and the
+// rest are returned in order of decreasing weight. Entries of equal weight
+// keep their original order.
+func parseQualityList(header string) []qualityValue {
+	var values []qualityValue
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		value := strings.TrimSpace(params[0])
+		if value == "" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			q, err := strconv.ParseFloat(param[len("q="):], 64)
+			if err != nil || q < 0 || q > 1 {
+				q = 0
+			}
+			quality = q
+		}
+
+		if quality > 0 {
+			values = append(values, qualityValue{value, quality})
+		}
+	}
+
+	sort.SliceStable(values, func(i, j int) bool {
+		return values[i].quality > values[j].quality
+	})
+
+	return values
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// OriginCacheStats holds counters describing the use of an
+// OriginValidatorCache.
+type OriginCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Size      int
+}
+
+// OriginValidatorCache memoizes the results of an OriginValidator for a
+// bounded number of origins. It is safe for concurrent use.
+//
+// Example:
+//
+//  cache := handlers.NewOriginValidatorCache(expensiveValidator, 1000, 5*time.Minute)
+//  handlers.CORS(handlers.AllowedOriginValidator(cache.Validate))
+type OriginValidatorCache struct {
+	validator OriginValidator
+	size      int
+	ttl       time.Duration
+	now       func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+	stats   OriginCacheStats
+}
+
+type originCacheEntry struct {
+	origin  string
+	allowed bool
+	expires time.Time
+}
+
+// NewOriginValidatorCache returns a cache of the results of validator that
+// holds at most size origins, each for at most ttl. When full, the least
+// recently used origin is evicted.
+func NewOriginValidatorCache(validator OriginValidator, size int, ttl time.Duration) *OriginValidatorCache {
+	if size < 1 {
+		size = 1
+	}
+
+	return &OriginValidatorCache{
+		validator: validator,
+		size:      size,
+		ttl:       ttl,
+		now:       time.Now,
+		entries:   make(map[string]*list.Element),
+		order:     list.New(),
+	}
+}
+
+// Validate reports whether origin is allowed, calling the underlying
+// validator only if no unexpired result is cached. It has the signature of
+// an OriginValidator.
+func (c *OriginValidatorCache) Validate(origin string) bool {
+	now := c.now()
+
+	c.mu.Lock()
+	if e, ok := c.entries[origin]; ok {
+		entry := e.Value.(*originCacheEntry)
+		if now.Before(entry.expires) {
+			c.order.MoveToFront(e)
+			c.stats.Hits++
+			c.mu.Unlock()
+			return entry.allowed
+		}
+		c.order.Remove(e)
+		delete(c.entries, origin)
+	}
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	// The validator is called without holding the lock, so that a slow
+	// validation does not block lookups of other origins.
+	allowed := c.validator(origin)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[origin]; ok {
+		c.order.Remove(e)
+	}
+	c.entries[origin] = c.order.PushFront(&originCacheEntry{
+		origin:  origin,
+		allowed: allowed,
+		expires: now.Add(c.ttl),
+	})
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*originCacheEntry).origin)
+		c.stats.Evictions++
+	}
+
+	return allowed
+}
+
+// Stats returns a snapshot of the cache counters.
+func (c *OriginValidatorCache) Stats() OriginCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Size = c.order.Len()
+	return stats
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOriginValidatorCache(t *testing.T) {
+	calls := map[string]int{}
+	validator := func(origin string) bool {
+		calls[origin]++
+		return origin == "https://good.com"
+	}
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := NewOriginValidatorCache(validator, 2, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	if !cache.Validate("https://good.com") || !cache.Validate("https://good.com") {
+		t.Fatal("expected https://good.com to be allowed")
+	}
+	if cache.Validate("https://bad.com") || cache.Validate("https://bad.com") {
+		t.Fatal("expected https://bad.com to be denied")
+	}
+	if calls["https://good.com"] != 1 || calls["https://bad.com"] != 1 {
+		t.Fatalf("expected one validation per origin, got %v", calls)
+	}
+	if got, want := cache.Stats(), (OriginCacheStats{Hits: 2, Misses: 2, Size: 2}); got != want {
+		t.Fatalf("bad stats: got %+v want %+v", got, want)
+	}
+
+	// Expiry.
+	now = now.Add(time.Minute)
+	cache.Validate("https://good.com")
+	if got, want := calls["https://good.com"], 2; got != want {
+		t.Fatalf("expected revalidation after the TTL: got %v calls want %v", got, want)
+	}
+
+	// Eviction of the least recently used origin.
+	cache.Validate("https://third.com")
+	if got, want := cache.Stats().Evictions, uint64(1); got != want {
+		t.Fatalf("bad evictions: got %v want %v", got, want)
+	}
+	cache.Validate("https://good.com")
+	if got, want := calls["https://good.com"], 2; got != want {
+		t.Fatalf("recently used origin was evicted: got %v calls want %v", got, want)
+	}
+	cache.Validate("https://bad.com")
+	if got, want := calls["https://bad.com"], 2; got != want {
+		t.Fatalf("least recently used origin was not evicted: got %v calls want %v", got, want)
+	}
+	if got, want := cache.Stats().Size, 2; got != want {
+		t.Fatalf("bad size: got %v want %v", got, want)
+	}
+}
+
+func TestOriginValidatorCacheWithCORS(t *testing.T) {
+	calls := 0
+	cache := NewOriginValidatorCache(func(origin string) bool {
+		calls++
+		return true
+	}, 10, time.Minute)
+
+	handler := CORS(DisallowDefaultOrigins(), AllowedOriginValidator(cache.Validate))(okHandler)
+	for i := 0; i < 3; i++ {
+		r := newRequest("GET", "http://www.example.com/")
+		r.Header.Set("Origin", "https://a.com")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, r)
+
+		if got, want := rr.Header().Get(corsAllowOriginHeader), "https://a.com"; got != want {
+			t.Fatalf("bad header: expected %q, got %q.", want, got)
+		}
+	}
+
+	if got, want := calls, 1; got != want {
+		t.Fatalf("bad validator call count: got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OriginsFile is a list of allowed origins loaded from a file that can be
+// edited while the server runs. The file holds one or more origins per line,
+// separated by commas, as accepted by CORSAllowedOriginsFromEnv; text after a
+// "#" is a comment. It is safe for concurrent use.
+//
+// Example:
+//
+//  origins, err := handlers.WatchOriginsFile("/etc/app/origins.txt", 10*time.Second, nil)
+//  if err != nil {
+//  	log.Fatal(err)
+//  }
+//  defer origins.Close()
+//
+//  http.ListenAndServe(":1123", handlers.CORS(origins.Option())(r))
+type OriginsFile struct {
+	path   string
+	logger RecoveryHandlerLogger
+
+	mu      sync.RWMutex
+	origins []string
+	modTime time.Time
+	size    int64
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// WatchOriginsFile loads the origins in the file at path and, if interval is
+// positive, checks the file for changes every interval, reloading it when its
+// size or modification time changes. An error is returned if the file cannot
+// be loaded initially. Later errors are logged to logger, or with the log
+// package if logger is nil, and leave the previously loaded origins in place.
+func WatchOriginsFile(path string, interval time.Duration, logger RecoveryHandlerLogger) (*OriginsFile, error) {
+	f := &OriginsFile{
+		path:   path,
+		logger: logger,
+		stop:   make(chan struct{}),
+	}
+	if err := f.Reload(); err != nil {
+		return nil, err
+	}
+
+	if interval > 0 {
+		go f.watch(interval)
+	}
+
+	return f, nil
+}
+
+// Origins returns the currently allowed origins.
+func (f *OriginsFile) Origins() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.origins
+}
+
+// Option returns a CORSOption that allows the origins currently in the file.
+func (f *OriginsFile) Option() CORSOption {
+	return AllowedOriginsFunc(func(*http.Request) []string {
+		return f.Origins()
+	})
+}
+
+// Reload reads the file again. If it cannot be read or parsed, the
+// previously loaded origins are kept and the error is returned.
+func (f *OriginsFile) Reload() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	b, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(string(b), "\n")
+	for i, line := range lines {
+		if j := strings.Index(line, "#"); j >= 0 {
+			lines[i] = line[:j]
+		}
+	}
+	origins, err := parseOriginList(strings.Join(lines, ","))
+	if err != nil {
+		return fmt.Errorf("handlers: parsing %s: %v", f.path, err)
+	}
+
+	f.mu.Lock()
+	f.origins = origins
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+	f.mu.Unlock()
+
+	return nil
+}
+
+// Close stops watching the file for changes.
+func (f *OriginsFile) Close() {
+	f.stopOnce.Do(func() {
+		close(f.stop)
+	})
+}
+
+func (f *OriginsFile) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(f.path)
+		if err != nil {
+			f.log(err)
+			continue
+		}
+
+		f.mu.RLock()
+		changed := !info.ModTime().Equal(f.modTime) || info.Size() != f.size
+		f.mu.RUnlock()
+
+		if changed {
+			if err := f.Reload(); err != nil {
+				f.log(err)
+				// Remember the broken version so the error is logged once.
+				f.mu.Lock()
+				f.modTime = info.ModTime()
+				f.size = info.Size()
+				f.mu.Unlock()
+			}
+		}
+	}
+}
+
+func (f *OriginsFile) log(v ...interface{}) {
+	if f.logger != nil {
+		f.logger.Println(v...)
+	} else {
+		log.Println(v...)
+	}
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Len()
+}
+
+func writeOriginsFile(t *testing.T, path, content string) {
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOriginsFileReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorilla_origins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "origins.txt")
+
+	writeOriginsFile(t, path, "# production\nhttps://a.example.com\n")
+	origins, err := WatchOriginsFile(path, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer origins.Close()
+	handler := CORS(origins.Option())(okHandler)
+
+	allowOrigin := func(origin string) string {
+		r := newRequest("GET", "http://www.example.com/")
+		r.Header.Set("Origin", origin)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, r)
+		return rr.Header().Get(corsAllowOriginHeader)
+	}
+
+	if got, want := allowOrigin("https://b.example.com"), ""; got != want {
+		t.Fatalf("bad header: expected %q, got %q.", want, got)
+	}
+
+	writeOriginsFile(t, path, "https://a.example.com, https://b.example.com\n")
+	if err := origins.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := allowOrigin("https://b.example.com"), "https://b.example.com"; got != want {
+		t.Fatalf("bad header after reload: expected %q, got %q.", want, got)
+	}
+
+	writeOriginsFile(t, path, "https://a.example.com/path\n")
+	if err := origins.Reload(); err == nil {
+		t.Fatal("expected error for malformed file")
+	}
+	if got, want := origins.Origins(), []string{"https://a.example.com", "https://b.example.com"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("bad origins after malformed reload: got %q want %q", got, want)
+	}
+}
+
+func TestOriginsFileWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gorilla_origins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "origins.txt")
+
+	writeOriginsFile(t, path, "https://a.example.com\n")
+	var buf syncBuffer
+	origins, err := WatchOriginsFile(path, 5*time.Millisecond, log.New(&buf, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer origins.Close()
+
+	writeOriginsFile(t, path, "https://a.example.com\nhttps://c.example.com\n")
+	want := []string{"https://a.example.com", "https://c.example.com"}
+	for deadline := time.Now().Add(2 * time.Second); !reflect.DeepEqual(origins.Origins(), want); {
+		if time.Now().After(deadline) {
+			t.Fatalf("file change not picked up: got %q want %q", origins.Origins(), want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	writeOriginsFile(t, path, "not an origin list at all\n")
+	for deadline := time.Now().Add(2 * time.Second); buf.Len() == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("malformed file not logged")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := origins.Origins(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("bad origins after malformed file: got %q want %q", got, want)
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"net"
 	"net/http"
 	"regexp"
 	"strings"
@@ -42,24 +43,100 @@ var (
 // headers for validating the 'trustworthiness' of a request.
 func ProxyHeaders(h http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
-		// Set the remote IP with the value passed from the proxy.
-		if fwd := getIP(r); fwd != "" {
-			r.RemoteAddr = fwd
+		applyProxyHeaders(r)
+		// Call the next handler in the chain.
+		h.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// TrustedProxyHeaders is like ProxyHeaders, but only honours the proxy
+// headers of requests whose immediate peer is in one of the trusted CIDR
+// ranges, such as "10.0.0.0/8". Headers sent by other peers are ignored.
+//
+// In addition, r.URL.Scheme and r.URL.Host are always populated, from the
+// proxy headers of trusted peers or else from the connection, so that
+// handlers can build absolute URLs from r.URL. An error is returned if a
+// range cannot be parsed.
+//
+// Example:
+//
+//  proxies, err := handlers.TrustedProxyHeaders([]string{"10.0.0.0/8"})
+//  if err != nil {
+//      log.Fatal(err)
+//  }
+//  http.ListenAndServe(":1123", proxies(r))
+func TrustedProxyHeaders(trusted []string) (func(http.Handler) http.Handler, error) {
+	nets := make([]*net.IPNet, 0, len(trusted))
+	for _, cidr := range trusted {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
 		}
+		nets = append(nets, n)
+	}
+
+	return func(h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if isTrustedPeer(r.RemoteAddr, nets) {
+				applyProxyHeaders(r)
+			}
 
-		// Set the scheme (proto) with the value passed from the proxy.
-		if scheme := getScheme(r); scheme != "" {
-			r.URL.Scheme = scheme
+			if r.URL.Scheme == "" {
+				r.URL.Scheme = "http"
+				if r.TLS != nil {
+					r.URL.Scheme = "https"
+				}
+			}
+			if r.URL.Host == "" {
+				r.URL.Host = r.Host
+			}
+
+			h.ServeHTTP(w, r)
 		}
-		// Set the host with the value passed by the proxy
-		if r.Header.Get(xForwardedHost) != "" {
-			r.Host = r.Header.Get(xForwardedHost)
+
+		return http.HandlerFunc(fn)
+	}, nil
+}
+
+// applyProxyHeaders updates r with the values passed by a proxy.
+func applyProxyHeaders(r *http.Request) {
+	// Set the remote IP with the value passed from the proxy.
+	if fwd := getIP(r); fwd != "" {
+		r.RemoteAddr = fwd
+	}
+
+	// Set the scheme (proto) with the value passed from the proxy.
+	if scheme := getScheme(r); scheme != "" {
+		r.URL.Scheme = scheme
+	}
+	// Set the host with the value passed by the proxy
+	if r.Header.Get(xForwardedHost) != "" {
+		r.Host = r.Header.Get(xForwardedHost)
+	}
+}
+
+// isTrustedPeer reports whether the IP address of remoteAddr is in one of
+// nets.
+func isTrustedPeer(remoteAddr string, nets []*net.IPNet) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
 		}
-		// Call the next handler in the chain.
-		h.ServeHTTP(w, r)
 	}
 
-	return http.HandlerFunc(fn)
+	return false
 }
 
 // getIP retrieves the IP from the X-Forwarded-For, X-Real-IP and RFC7239
@@ -109,3 +109,39 @@ func TestProxyHeaders(t *testing.T) {
 	}
 
 }
+
+func TestTrustedProxyHeaders(t *testing.T) {
+	proxies, err := TrustedProxyHeaders([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		remoteAddr string
+		want       string
+	}{
+		{"10.1.2.3:4567", "https://www.example.com/users"},
+		{"203.0.113.7:4567", "http://internal.local/users"},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("GET", "/users")
+		r.Host = "internal.local"
+		r.RemoteAddr = tt.remoteAddr
+		r.Header.Set(xForwardedProto, "https")
+		r.Header.Set(xForwardedHost, "www.example.com")
+
+		var got string
+		proxies(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r.URL.String()
+		})).ServeHTTP(httptest.NewRecorder(), r)
+
+		if got != tt.want {
+			t.Fatalf("wrong URL for %s: got %s want %s", tt.remoteAddr, got, tt.want)
+		}
+	}
+
+	if _, err := TrustedProxyHeaders([]string{"not-a-cidr"}); err == nil {
+		t.Fatal("expected an error for an invalid range")
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// QueryLimit is HTTP middleware that rejects requests whose query string
+// holds more than maxKeys distinct keys or more than maxParams parameters in
+// total with 400 Bad Request. The query is scanned without being fully
+// decoded and the scan stops as soon as a limit is exceeded, so the cost of a
+// hostile query is bounded before the handler calls r.URL.Query(). A limit of
+// zero disables that check.
+//
+// Example:
+//
+//  r := mux.NewRouter()
+//  r.HandleFunc("/search", SearchHandler)
+//
+//  http.ListenAndServe(":1123", handlers.QueryLimit(32, 256)(r))
+func QueryLimit(maxKeys, maxParams int) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !queryWithinLimits(r.URL.RawQuery, maxKeys, maxParams) {
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// queryWithinLimits reports whether query has at most maxKeys distinct keys
+// and maxParams parameters. Parameters are split the same way as
+// url.ParseQuery splits them.
+func queryWithinLimits(query string, maxKeys, maxParams int) bool {
+	if maxKeys <= 0 && maxParams <= 0 {
+		return true
+	}
+
+	params := 0
+	keys := make(map[string]struct{})
+	for query != "" {
+		param := query
+		if i := strings.IndexAny(param, "&;"); i >= 0 {
+			param, query = param[:i], param[i+1:]
+		} else {
+			query = ""
+		}
+		if param == "" {
+			continue
+		}
+
+		params++
+		if maxParams > 0 && params > maxParams {
+			return false
+		}
+
+		if maxKeys > 0 {
+			key := param
+			if i := strings.Index(key, "="); i >= 0 {
+				key = key[:i]
+			}
+			if unescaped, err := url.QueryUnescape(key); err == nil {
+				key = unescaped
+			}
+			keys[key] = struct{}{}
+			if len(keys) > maxKeys {
+				return false
+			}
+		}
+	}
+
+	return true
+}
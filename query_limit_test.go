@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQueryLimit(t *testing.T) {
+	tests := []struct {
+		query  string
+		status int
+	}{
+		{"", http.StatusOK},
+		{"a=1&b=2&c=3", http.StatusOK},
+		{"a=1&b=2&c=3&d=4", http.StatusBadRequest},
+		{"a=1&a=2&a=3&b=4&b=5", http.StatusOK},
+		{"a=1&a=2&a=3&b=4&b=5&b=6", http.StatusBadRequest},
+		{"a=1&&&b=2;c=3", http.StatusOK},
+		{"a=1&%61=2&b&c", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("GET", "http://www.example.com/?"+tt.query)
+		rr := httptest.NewRecorder()
+
+		QueryLimit(3, 5)(okHandler).ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.status; got != want {
+			t.Fatalf("bad status for %q: got %v want %v", tt.query, got, want)
+		}
+	}
+}
+
+func TestQueryLimitDisabled(t *testing.T) {
+	r := newRequest("GET", "http://www.example.com/?a=1&b=2&c=3&d=4&e=5&f=6")
+	rr := httptest.NewRecorder()
+
+	QueryLimit(0, 0)(okHandler).ServeHTTP(rr, r)
+
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// QueryParamsOption provides a functional approach to configure the
+// AllowedQueryParams middleware.
+type QueryParamsOption func(*queryParamsHandler)
+
+type queryParamsHandler struct {
+	h       http.Handler
+	allowed map[string]bool
+	logOnly bool
+	logger  RecoveryHandlerLogger
+}
+
+// AllowedQueryParams is HTTP middleware that rejects requests with query
+// parameters whose key is not in allowed with 400 Bad Request, to catch
+// client bugs early. The response body lists the unexpected keys.
+//
+// Example:
+//
+//  r := mux.NewRouter()
+//  r.HandleFunc("/search", SearchHandler)
+//
+//  http.ListenAndServe(":1123", handlers.AllowedQueryParams([]string{"q", "page"})(r))
+func AllowedQueryParams(allowed []string, opts ...QueryParamsOption) func(http.Handler) http.Handler {
+	keys := make(map[string]bool, len(allowed))
+	for _, key := range allowed {
+		keys[key] = true
+	}
+
+	return func(h http.Handler) http.Handler {
+		qh := &queryParamsHandler{
+			h:       h,
+			allowed: keys,
+		}
+
+		for _, option := range opts {
+			option(qh)
+		}
+
+		return qh
+	}
+}
+
+// QueryParamsLogOnly makes AllowedQueryParams log requests with unexpected
+// query parameters and serve them as usual, for example while clients are
+// migrated.
+func QueryParamsLogOnly() QueryParamsOption {
+	return func(qh *queryParamsHandler) {
+		qh.logOnly = true
+	}
+}
+
+// QueryParamsLogger is a functional option to override the default logger
+// used in log-only mode.
+func QueryParamsLogger(logger RecoveryHandlerLogger) QueryParamsOption {
+	return func(qh *queryParamsHandler) {
+		qh.logger = logger
+	}
+}
+
+func (qh *queryParamsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var unexpected []string
+	for key := range r.URL.Query() {
+		if !qh.allowed[key] {
+			unexpected = append(unexpected, key)
+		}
+	}
+	if len(unexpected) == 0 {
+		qh.h.ServeHTTP(w, r)
+		return
+	}
+
+	sort.Strings(unexpected)
+	message := "unexpected query parameters: " + strings.Join(unexpected, ", ")
+
+	if !qh.logOnly {
+		http.Error(w, message, http.StatusBadRequest)
+		return
+	}
+
+	if qh.logger != nil {
+		qh.logger.Println("handlers:", r.Method, r.URL.Path, message)
+	} else {
+		log.Println("handlers:", r.Method, r.URL.Path, message)
+	}
+
+	qh.h.ServeHTTP(w, r)
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAllowedQueryParams(t *testing.T) {
+	handler := AllowedQueryParams([]string{"q", "page"})(okHandler)
+
+	tests := []struct {
+		query  string
+		status int
+		body   string
+	}{
+		{"", http.StatusOK, ""},
+		{"q=gorilla&page=2", http.StatusOK, ""},
+		{"q=gorilla&sort=asc&limit=10", http.StatusBadRequest, "unexpected query parameters: limit, sort"},
+	}
+
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, newRequest("GET", "http://www.example.com/search?"+tt.query))
+
+		if got, want := rr.Code, tt.status; got != want {
+			t.Fatalf("bad status for %q: got %v want %v", tt.query, got, want)
+		}
+		if tt.body != "" && strings.TrimSpace(rr.Body.String()) != tt.body {
+			t.Fatalf("bad body for %q: got %q want %q", tt.query, rr.Body.String(), tt.body)
+		}
+	}
+}
+
+func TestAllowedQueryParamsLogOnly(t *testing.T) {
+	var buf bytes.Buffer
+	handler := AllowedQueryParams([]string{"q"}, QueryParamsLogOnly(), QueryParamsLogger(log.New(&buf, "", 0)))(okHandler)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newRequest("GET", "http://www.example.com/search?q=gorilla&sort=asc"))
+
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+	if !strings.Contains(buf.String(), "unexpected query parameters: sort") {
+		t.Fatalf("Got log %#v, wanted substring %#v", buf.String(), "unexpected query parameters: sort")
+	}
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitStore keeps track of the requests made for each rate limit key.
+// Implementations backed by shared storage such as Redis allow a limit to be
+// enforced across several instances of a service.
+type RateLimitStore interface {
+	// Take records a request for key at time now. It reports whether the
+	// request is allowed and, if it is not, how long the client should wait
+	// before trying again.
+	Take(key string, now time.Time) (allowed bool, retryAfter time.Duration)
+}
+
+// RateLimitOption provides a functional approach to configure the RateLimit
+// middleware.
+type RateLimitOption func(*rateLimiter)
+
+type rateLimiter struct {
+	h     http.Handler
+	store RateLimitStore
+	key   func(r *http.Request) string
+}
+
+// RateLimit is HTTP middleware that allows at most limit requests per client
+// in every period, responding with 429 Too Many Requests and a Retry-After
+// header once the limit is exceeded. Clients are identified by the IP address
+// in r.RemoteAddr, so place RateLimit after ProxyHeaders when running behind a
+// reverse proxy.
+//
+// Request counts are kept in memory by default; use RateLimitWithStore to
+// share them between instances. RateLimit panics if limit is less than 1 or
+// period is not positive.
+//
+// Example:
+//
+//  r := mux.NewRouter()
+//  r.HandleFunc("/", SearchHandler)
+//
+//  http.ListenAndServe(":1123", handlers.RateLimit(10, time.Second)(r))
+func RateLimit(limit int, period time.Duration, opts ...RateLimitOption) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		rl := &rateLimiter{
+			h:     h,
+			store: NewMemoryRateLimitStore(limit, period),
+			key:   clientIP,
+		}
+
+		for _, option := range opts {
+			option(rl)
+		}
+
+		return rl
+	}
+}
+
+// RouteRateLimit is HTTP middleware that allows at most limit requests in
+// every period for each route, whichever client makes them, to protect
+// expensive endpoints. routeKey maps a request to its route, for example the
+// template of the matched route; requests with the same key share a limit.
+// Requests over the limit receive 429 Too Many Requests with a Retry-After
+// header.
+//
+// RouteRateLimit may be combined with RateLimit to enforce both a per-client
+// and a per-route limit.
+//
+// Example:
+//
+//  route := func(r *http.Request) string { return r.URL.Path }
+//  http.ListenAndServe(":1123", handlers.RouteRateLimit(100, time.Second, route)(r))
+func RouteRateLimit(limit int, period time.Duration, routeKey func(r *http.Request) string, opts ...RateLimitOption) func(http.Handler) http.Handler {
+	return RateLimit(limit, period, append([]RateLimitOption{RateLimitKey(routeKey)}, opts...)...)
+}
+
+// RateLimitWithStore replaces the in-memory store used to count requests.
+func RateLimitWithStore(store RateLimitStore) RateLimitOption {
+	return func(rl *rateLimiter) {
+		rl.store = store
+	}
+}
+
+// RateLimitKey sets the function used to group requests. Requests that map
+// to the same key share a limit. The default key is the client IP address.
+func RateLimitKey(fn func(r *http.Request) string) RateLimitOption {
+	return func(rl *rateLimiter) {
+		rl.key = fn
+	}
+}
+
+func (rl *rateLimiter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	allowed, retryAfter := rl.store.Take(rl.key(r), time.Now())
+	if !allowed {
+		writeTooManyRequests(w, retryAfter)
+		return
+	}
+
+	rl.h.ServeHTTP(w, r)
+}
+
+// writeTooManyRequests responds with 429 Too Many Requests and a Retry-After
+// header of retryAfter, rounded up to at least one second.
+func writeTooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+}
+
+// clientIP returns the host part of r.RemoteAddr.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// memoryRateLimitSweepSize is the number of keys above which idle buckets are
+// removed from a memoryRateLimitStore.
+const memoryRateLimitSweepSize = 10000
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type memoryRateLimitStore struct {
+	mu       sync.Mutex
+	capacity float64
+	rate     float64 // tokens per second
+	buckets  map[string]*tokenBucket
+}
+
+// NewMemoryRateLimitStore returns a RateLimitStore that keeps a token bucket
+// per key in memory. Each bucket holds up to limit tokens and is refilled at a
+// rate of limit tokens per period. It panics if limit is less than 1 or
+// period is not positive.
+func NewMemoryRateLimitStore(limit int, period time.Duration) RateLimitStore {
+	if limit < 1 || period <= 0 {
+		panic(fmt.Sprintf("handlers: invalid rate limit of %d requests per %v; limit must be at least 1 and period positive", limit, period))
+	}
+
+	return &memoryRateLimitStore{
+		capacity: float64(limit),
+		rate:     float64(limit) / period.Seconds(),
+		buckets:  make(map[string]*tokenBucket),
+	}
+}
+
+func (s *memoryRateLimitStore) Take(key string, now time.Time) (bool, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		if len(s.buckets) >= memoryRateLimitSweepSize {
+			s.sweep(now)
+		}
+		b = &tokenBucket{tokens: s.capacity, last: now}
+		s.buckets[key] = b
+	}
+
+	b.tokens = s.refill(b, now)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := (1 - b.tokens) / s.rate
+		return false, time.Duration(wait * float64(time.Second))
+	}
+
+	b.tokens--
+	return true, 0
+}
+
+func (s *memoryRateLimitStore) refill(b *tokenBucket, now time.Time) float64 {
+	tokens := b.tokens + now.Sub(b.last).Seconds()*s.rate
+	if tokens > s.capacity {
+		tokens = s.capacity
+	}
+
+	return tokens
+}
+
+// sweep removes buckets that have refilled completely, as they are
+// indistinguishable from new ones.
+func (s *memoryRateLimitStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if s.refill(b, now) >= s.capacity {
+			delete(s.buckets, key)
+		}
+	}
+}
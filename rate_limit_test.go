@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type fakeRateLimitStore struct {
+	keys       []string
+	allowed    bool
+	retryAfter time.Duration
+}
+
+func (s *fakeRateLimitStore) Take(key string, now time.Time) (bool, time.Duration) {
+	s.keys = append(s.keys, key)
+	return s.allowed, s.retryAfter
+}
+
+func TestRateLimitInMemory(t *testing.T) {
+	handler := RateLimit(2, time.Minute)(okHandler)
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		r := newRequest("GET", "/")
+		r.RemoteAddr = "192.0.2.1:1234"
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got := rr.Code; got != want {
+			t.Fatalf("request %d: bad status: got %v want %v", i, got, want)
+		}
+	}
+
+	// A different client has its own limit.
+	r := newRequest("GET", "/")
+	r.RemoteAddr = "192.0.2.2:1234"
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+}
+
+func TestMemoryRateLimitStoreRefill(t *testing.T) {
+	store := NewMemoryRateLimitStore(1, time.Second)
+	now := time.Now()
+
+	if allowed, _ := store.Take("k", now); !allowed {
+		t.Fatal("first request should be allowed")
+	}
+	allowed, retryAfter := store.Take("k", now)
+	if allowed {
+		t.Fatal("second request should be limited")
+	}
+	if retryAfter != time.Second {
+		t.Fatalf("bad retry after: got %v want %v", retryAfter, time.Second)
+	}
+	if allowed, _ := store.Take("k", now.Add(time.Second)); !allowed {
+		t.Fatal("request after refill should be allowed")
+	}
+}
+
+func TestMemoryRateLimitStoreInvalid(t *testing.T) {
+	tests := []struct {
+		limit  int
+		period time.Duration
+	}{
+		{0, time.Second},
+		{-1, time.Second},
+		{1, 0},
+		{1, -time.Second},
+	}
+
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("expected panic for %d requests per %v", tt.limit, tt.period)
+				}
+			}()
+			NewMemoryRateLimitStore(tt.limit, tt.period)
+		}()
+	}
+}
+
+func TestRateLimitWithStore(t *testing.T) {
+	store := &fakeRateLimitStore{allowed: false, retryAfter: 1500 * time.Millisecond}
+	handler := RateLimit(1, time.Second, RateLimitWithStore(store))(okHandler)
+
+	r := newRequest("GET", "/")
+	r.RemoteAddr = "192.0.2.1:1234"
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+
+	if got, want := rr.Code, http.StatusTooManyRequests; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+	if got, want := rr.Header().Get("Retry-After"), "2"; got != want {
+		t.Fatalf("bad Retry-After: got %q want %q", got, want)
+	}
+	if got, want := store.keys, []string{"192.0.2.1"}; len(got) != 1 || got[0] != want[0] {
+		t.Fatalf("bad keys: got %v want %v", got, want)
+	}
+
+	store.allowed = true
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+}
+
+func TestRouteRateLimit(t *testing.T) {
+	route := func(r *http.Request) string { return r.URL.Path }
+	handler := RateLimit(10, time.Minute)(RouteRateLimit(3, time.Minute, route)(okHandler))
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		r := newRequest("GET", "/search")
+		r.RemoteAddr = "192.0.2." + strconv.Itoa(i+1) + ":1234"
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got := rr.Code; got != want {
+			t.Fatalf("request %d: bad status: got %v want %v", i, got, want)
+		}
+		if want == http.StatusTooManyRequests && rr.Header().Get("Retry-After") == "" {
+			t.Fatalf("request %d: missing Retry-After header", i)
+		}
+	}
+
+	// Other routes have their own limit.
+	r := newRequest("GET", "/home")
+	r.RemoteAddr = "192.0.2.1:1234"
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+}
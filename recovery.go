@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"encoding/json"
 	"log"
 	"net/http"
 	"runtime/debug"
@@ -12,9 +13,25 @@ type RecoveryHandlerLogger interface {
 }
 
 type recoveryHandler struct {
-	handler    http.Handler
-	logger     RecoveryHandlerLogger
-	printStack bool
+	handler        http.Handler
+	logger         RecoveryHandlerLogger
+	printStack     bool
+	problemDetails bool
+	problemType    string
+	statusFunc     RecoveryStatusFunc
+}
+
+// RecoveryStatusFunc maps a recovered panic value to the status code of the
+// response and any headers to add to it. A status of zero keeps the default
+// of 500 Internal Server Error.
+type RecoveryStatusFunc func(recovered interface{}) (status int, header http.Header)
+
+// problemDetails is an RFC 7807 Problem Details document.
+type problemDetails struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
 }
 
 // RecoveryOption provides a functional approach to define
@@ -67,10 +84,40 @@ func PrintRecoveryStack(print bool) RecoveryOption {
 	}
 }
 
+// RecoveryProblemDetails is a functional option to respond to a recovered
+// panic with an RFC 7807 application/problem+json document instead of an
+// empty body. typeURI identifies the problem type and is sent as the "type"
+// member; "about:blank" is used when it is empty. The panic value is not
+// included in the response.
+func RecoveryProblemDetails(typeURI string) RecoveryOption {
+	return func(h http.Handler) {
+		r := h.(*recoveryHandler)
+		r.problemDetails = true
+		r.problemType = typeURI
+	}
+}
+
+// RecoveryStatus is a functional option to choose the response status and
+// headers for a recovered panic value, for example to answer a panic with a
+// sentinel error type with 503 Service Unavailable and a Retry-After header:
+//
+//  handlers.RecoveryStatus(func(v interface{}) (int, http.Header) {
+//  	if _, ok := v.(*OverloadedError); ok {
+//  		return http.StatusServiceUnavailable, http.Header{"Retry-After": {"30"}}
+//  	}
+//  	return 0, nil
+//  })
+func RecoveryStatus(fn RecoveryStatusFunc) RecoveryOption {
+	return func(h http.Handler) {
+		r := h.(*recoveryHandler)
+		r.statusFunc = fn
+	}
+}
+
 func (h recoveryHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	defer func() {
 		if err := recover(); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			h.writeError(w, err)
 			h.log(err)
 		}
 	}()
@@ -78,6 +125,41 @@ func (h recoveryHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	h.handler.ServeHTTP(w, req)
 }
 
+func (h recoveryHandler) writeError(w http.ResponseWriter, recovered interface{}) {
+	status := http.StatusInternalServerError
+	if h.statusFunc != nil {
+		code, header := h.statusFunc(recovered)
+		if code != 0 {
+			status = code
+		}
+		for k, v := range header {
+			w.Header()[http.CanonicalHeaderKey(k)] = v
+		}
+	}
+
+	if !h.problemDetails {
+		w.WriteHeader(status)
+		return
+	}
+
+	problemType := h.problemType
+	if problemType == "" {
+		problemType = "about:blank"
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	problem := problemDetails{
+		Type:   problemType,
+		Title:  http.StatusText(status),
+		Status: status,
+	}
+	if status == http.StatusInternalServerError {
+		problem.Detail = "The server encountered an unexpected condition."
+	}
+	json.NewEncoder(w).Encode(problem)
+}
+
 func (h recoveryHandler) log(v ...interface{}) {
 	if h.logger != nil {
 		h.logger.Println(v...)
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"bytes"
+	"encoding/json"
 	"log"
 	"net/http"
 	"net/http/httptest"
@@ -56,3 +57,85 @@ func TestRecoveryLoggerWithCustomLogger(t *testing.T) {
 		}
 	})
 }
+
+func TestRecoveryProblemDetails(t *testing.T) {
+	var buf bytes.Buffer
+	var logger = log.New(&buf, "", log.LstdFlags)
+
+	handlerFunc := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		panic("Unexpected error!")
+	})
+
+	handler := RecoveryHandler(
+		RecoveryLogger(logger),
+		PrintRecoveryStack(true),
+		RecoveryProblemDetails("https://example.com/problems/internal"),
+	)
+
+	rr := httptest.NewRecorder()
+	handler(handlerFunc).ServeHTTP(rr, newRequest("GET", "/subdir/asdf"))
+
+	if got, want := rr.Code, http.StatusInternalServerError; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+	if got, want := rr.Header().Get("Content-Type"), "application/problem+json"; got != want {
+		t.Fatalf("bad content type: got %q want %q", got, want)
+	}
+
+	var problem problemDetails
+	if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	if problem.Type != "https://example.com/problems/internal" || problem.Title != "Internal Server Error" ||
+		problem.Status != http.StatusInternalServerError || problem.Detail == "" {
+		t.Fatalf("bad problem document: %+v", problem)
+	}
+
+	if !strings.Contains(buf.String(), "runtime/debug.Stack") {
+		t.Fatalf("Got log %#v, wanted substring %#v", buf.String(), "runtime/debug.Stack")
+	}
+}
+
+type overloadedError struct{}
+
+func (overloadedError) Error() string { return "overloaded" }
+
+func TestRecoveryStatus(t *testing.T) {
+	var buf bytes.Buffer
+	var logger = log.New(&buf, "", log.LstdFlags)
+
+	handler := RecoveryHandler(
+		RecoveryLogger(logger),
+		RecoveryStatus(func(v interface{}) (int, http.Header) {
+			if _, ok := v.(overloadedError); ok {
+				return http.StatusServiceUnavailable, http.Header{"Retry-After": {"30"}}
+			}
+			return 0, nil
+		}),
+	)
+
+	tests := []struct {
+		value      interface{}
+		status     int
+		retryAfter string
+	}{
+		{overloadedError{}, http.StatusServiceUnavailable, "30"},
+		{"Unexpected error!", http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		handlerFunc := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			panic(tt.value)
+		})
+
+		rr := httptest.NewRecorder()
+		handler(handlerFunc).ServeHTTP(rr, newRequest("GET", "/subdir/asdf"))
+
+		if got, want := rr.Code, tt.status; got != want {
+			t.Fatalf("bad status for %v: got %v want %v", tt.value, got, want)
+		}
+		if got, want := rr.Header().Get("Retry-After"), tt.retryAfter; got != want {
+			t.Fatalf("bad Retry-After for %v: got %q want %q", tt.value, got, want)
+		}
+	}
+}
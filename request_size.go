@@ -0,0 +1,72 @@
+package handlers
+
+import "net/http"
+
+// RequestSizeLimit is HTTP middleware that rejects requests whose URL or
+// headers are too large before they reach the handler. Requests with a URL
+// longer than maxURLLength bytes receive 414 URI Too Long, and requests whose
+// header names and values add up to more than maxHeaderBytes receive
+// 431 Request Header Fields Too Large. A limit of zero disables that check.
+//
+// Note that http.Server.MaxHeaderBytes already bounds what the server reads
+// from the connection; this middleware enforces a stricter, per-route limit.
+func RequestSizeLimit(maxURLLength, maxHeaderBytes int) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if maxURLLength > 0 && requestURILength(r) > maxURLLength {
+				http.Error(w, http.StatusText(http.StatusRequestURITooLong), http.StatusRequestURITooLong)
+				return
+			}
+
+			if maxHeaderBytes > 0 && headerSize(r.Header) > maxHeaderBytes {
+				http.Error(w, http.StatusText(http.StatusRequestHeaderFieldsTooLarge), http.StatusRequestHeaderFieldsTooLarge)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+func requestURILength(r *http.Request) int {
+	if r.RequestURI != "" {
+		return len(r.RequestURI)
+	}
+
+	return len(r.URL.RequestURI())
+}
+
+// headerSize sums the length of every header name and value in h.
+func headerSize(h http.Header) int {
+	size := 0
+	for k, values := range h {
+		for _, v := range values {
+			size += len(k) + len(v)
+		}
+	}
+
+	return size
+}
+
+// RequestBodyLimit is HTTP middleware that limits request bodies to maxBytes.
+// Requests that declare a larger Content-Length are rejected with 413 Request
+// Entity Too Large before any of the body is read. Bodies without a declared
+// length, such as chunked uploads, are wrapped with http.MaxBytesReader, so
+// reading past the limit fails with an error and the handler decides how to
+// respond.
+func RequestBodyLimit(maxBytes int64) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > maxBytes {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
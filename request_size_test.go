@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestSizeLimit(t *testing.T) {
+	handler := RequestSizeLimit(64, 128)(okHandler)
+
+	tests := []struct {
+		url    string
+		header string
+		code   int
+	}{
+		{"/short", "small", http.StatusOK},
+		{"/" + strings.Repeat("a", 64), "small", http.StatusRequestURITooLong},
+		{"/short?q=" + strings.Repeat("a", 64), "small", http.StatusRequestURITooLong},
+		{"/short", strings.Repeat("b", 128), http.StatusRequestHeaderFieldsTooLarge},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("GET", tt.url)
+		r.Header.Set("X-Test", tt.header)
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.code; got != want {
+			t.Fatalf("bad status for %q: got %v want %v", tt.url, got, want)
+		}
+	}
+}
+
+func TestRequestBodyLimitDeclaredLength(t *testing.T) {
+	called := false
+	handler := RequestBodyLimit(8)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	r, _ := http.NewRequest("POST", "/", strings.NewReader("too large body"))
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, r)
+
+	if got, want := rr.Code, http.StatusRequestEntityTooLarge; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+	if called {
+		t.Fatal("handler called for an over-limit Content-Length")
+	}
+}
+
+func TestRequestBodyLimitChunked(t *testing.T) {
+	tests := []struct {
+		body    string
+		wantErr bool
+	}{
+		{"12345678", false},
+		{"123456789", true},
+	}
+
+	for _, tt := range tests {
+		var readErr error
+		handler := RequestBodyLimit(8)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, readErr = ioutil.ReadAll(r.Body)
+		}))
+
+		r, _ := http.NewRequest("POST", "/", ioutil.NopCloser(strings.NewReader(tt.body)))
+		r.ContentLength = -1
+		r.TransferEncoding = []string{"chunked"}
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got := readErr != nil; got != tt.wantErr {
+			t.Fatalf("bad read error for %q: got %v", tt.body, readErr)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+// RequireHeaderOption provides a functional approach to configure the
+// RequireHeader middleware.
+type RequireHeaderOption func(*requireHeaderHandler)
+
+type requireHeaderHandler struct {
+	h       http.Handler
+	name    string
+	allowed []string
+	status  int
+	body    string
+}
+
+// RequireHeader is HTTP middleware that rejects requests lacking the header
+// name, or whose value is not one of allowed, with 400 Bad Request. An empty
+// allowed list accepts any non-empty value.
+//
+// Example:
+//
+//  r := mux.NewRouter()
+//  r.HandleFunc("/", APIHandler)
+//
+//  http.ListenAndServe(":1123", handlers.RequireHeader("X-API-Version", []string{"1", "2"})(r))
+func RequireHeader(name string, allowed []string, opts ...RequireHeaderOption) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		rh := &requireHeaderHandler{
+			h:       h,
+			name:    name,
+			allowed: allowed,
+			status:  http.StatusBadRequest,
+		}
+
+		for _, option := range opts {
+			option(rh)
+		}
+
+		if rh.body == "" {
+			rh.body = http.StatusText(rh.status)
+		}
+
+		return rh
+	}
+}
+
+// RequireHeaderStatus sets the status code of the response to rejected
+// requests. The default is 400.
+func RequireHeaderStatus(code int) RequireHeaderOption {
+	return func(rh *requireHeaderHandler) {
+		rh.status = code
+	}
+}
+
+// RequireHeaderBody sets the body of the response to rejected requests. The
+// default is the status text of the response status.
+func RequireHeaderBody(body string) RequireHeaderOption {
+	return func(rh *requireHeaderHandler) {
+		rh.body = body
+	}
+}
+
+func (rh *requireHeaderHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	value := r.Header.Get(rh.name)
+	if value == "" || (len(rh.allowed) > 0 && !isMatch(value, rh.allowed)) {
+		http.Error(w, rh.body, rh.status)
+		return
+	}
+
+	rh.h.ServeHTTP(w, r)
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequireHeader(t *testing.T) {
+	tests := []struct {
+		allowed []string
+		value   string
+		status  int
+	}{
+		{[]string{"1", "2"}, "", http.StatusBadRequest},
+		{[]string{"1", "2"}, "3", http.StatusBadRequest},
+		{[]string{"1", "2"}, "2", http.StatusOK},
+		{nil, "", http.StatusBadRequest},
+		{nil, "anything", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("GET", "/")
+		if tt.value != "" {
+			r.Header.Set("X-API-Version", tt.value)
+		}
+		rr := httptest.NewRecorder()
+
+		RequireHeader("X-API-Version", tt.allowed)(okHandler).ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.status; got != want {
+			t.Fatalf("bad status for %q: got %v want %v", tt.value, got, want)
+		}
+	}
+}
+
+func TestRequireHeaderCustomResponse(t *testing.T) {
+	rr := httptest.NewRecorder()
+	RequireHeader("X-API-Version", []string{"1"},
+		RequireHeaderStatus(http.StatusPreconditionFailed),
+		RequireHeaderBody("unsupported API version"),
+	)(okHandler).ServeHTTP(rr, newRequest("GET", "/"))
+
+	if got, want := rr.Code, http.StatusPreconditionFailed; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+	if got, want := strings.TrimSpace(rr.Body.String()), "unsupported API version"; got != want {
+		t.Fatalf("bad body: got %q want %q", got, want)
+	}
+}
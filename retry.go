@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+var (
+	defaultRetryAttempts    = 2
+	defaultRetryBackoff     = 100 * time.Millisecond
+	defaultRetryMethods     = []string{"GET", "HEAD", "PUT", "DELETE"}
+	defaultRetryStatusCodes = []int{http.StatusBadGateway, http.StatusServiceUnavailable}
+	defaultRetryMaxBody     = int64(1 << 20)
+)
+
+// RetryOption provides a functional approach to configure the retry handler.
+type RetryOption func(*retryHandler)
+
+type retryHandler struct {
+	h           http.Handler
+	attempts    int
+	backoff     time.Duration
+	methods     []string
+	statusCodes []int
+	maxBody     int64
+}
+
+// bufferedResponseWriter is a http.ResponseWriter that keeps the status code,
+// headers and body in memory so they can be inspected before being sent to
+// the client.
+type bufferedResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedResponseWriter() *bufferedResponseWriter {
+	return &bufferedResponseWriter{header: make(http.Header)}
+}
+
+func (b *bufferedResponseWriter) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponseWriter) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+func (b *bufferedResponseWriter) WriteHeader(code int) {
+	if b.status == 0 {
+		b.status = code
+	}
+}
+
+// flush copies the buffered response to w.
+func (b *bufferedResponseWriter) flush(w http.ResponseWriter) {
+	for k, v := range b.header {
+		w.Header()[k] = v
+	}
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	w.WriteHeader(b.status)
+	w.Write(b.body.Bytes())
+}
+
+// Retry is HTTP middleware that re-runs the wrapped handler when it responds
+// with 502 Bad Gateway or 503 Service Unavailable. The request body is
+// buffered so it can be replayed, and the response of each attempt is held in
+// memory so that only the final one reaches the client.
+//
+// Only idempotent methods (GET, HEAD, PUT and DELETE) are retried by default;
+// other requests are passed straight through to the handler, as are requests
+// with a body larger than the limit set by RetryMaxBody, 1MB by default. Each
+// attempt is given its own copy of the request, so changes made by one
+// attempt do not leak into the next.
+//
+// Example:
+//
+//  r := mux.NewRouter()
+//  r.HandleFunc("/", UpstreamHandler)
+//
+//  http.ListenAndServe(":1123", handlers.Retry(handlers.RetryAttempts(3))(r))
+func Retry(opts ...RetryOption) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		rh := &retryHandler{
+			h:           h,
+			attempts:    defaultRetryAttempts,
+			backoff:     defaultRetryBackoff,
+			methods:     defaultRetryMethods,
+			statusCodes: defaultRetryStatusCodes,
+			maxBody:     defaultRetryMaxBody,
+		}
+
+		for _, option := range opts {
+			option(rh)
+		}
+
+		return rh
+	}
+}
+
+// RetryAttempts sets the maximum number of times the handler is retried after
+// the first attempt. The default is 2.
+func RetryAttempts(n int) RetryOption {
+	return func(rh *retryHandler) {
+		if n < 0 {
+			n = 0
+		}
+		rh.attempts = n
+	}
+}
+
+// RetryBackoff sets the delay before the first retry. The delay doubles on
+// every subsequent retry. The default is 100ms.
+func RetryBackoff(d time.Duration) RetryOption {
+	return func(rh *retryHandler) {
+		rh.backoff = d
+	}
+}
+
+// RetryMethods replaces the list of methods considered safe to retry. Only
+// add methods to this list when the handler is known to be idempotent for
+// them.
+func RetryMethods(methods []string) RetryOption {
+	return func(rh *retryHandler) {
+		rh.methods = methods
+	}
+}
+
+// RetryStatusCodes replaces the list of response status codes that trigger a
+// retry. The default is 502 and 503.
+func RetryStatusCodes(codes []int) RetryOption {
+	return func(rh *retryHandler) {
+		rh.statusCodes = codes
+	}
+}
+
+// RetryMaxBody sets the largest request body, in bytes, that is buffered to
+// be replayed. Requests with larger bodies are served once, without retries.
+// The default is 1MB.
+func RetryMaxBody(n int64) RetryOption {
+	return func(rh *retryHandler) {
+		rh.maxBody = n
+	}
+}
+
+func (rh *retryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !isMatch(r.Method, rh.methods) {
+		rh.h.ServeHTTP(w, r)
+		return
+	}
+
+	var body []byte
+	if r.Body != nil {
+		if r.ContentLength > rh.maxBody {
+			rh.h.ServeHTTP(w, r)
+			return
+		}
+
+		b, err := ioutil.ReadAll(io.LimitReader(r.Body, rh.maxBody+1))
+		if err != nil {
+			r.Body.Close()
+			http.Error(w, "Error reading request body", http.StatusBadRequest)
+			return
+		}
+		if int64(len(b)) > rh.maxBody {
+			// The body is too large to replay, so it is served once with the
+			// bytes already read put back in front of the rest.
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(b), r.Body), r.Body}
+			rh.h.ServeHTTP(w, r)
+			return
+		}
+		r.Body.Close()
+		body = b
+	}
+
+	backoff := rh.backoff
+	for attempt := 0; ; attempt++ {
+		req := r.Clone(r.Context())
+		if r.Body != nil {
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		bw := newBufferedResponseWriter()
+		rh.h.ServeHTTP(bw, req)
+
+		if attempt >= rh.attempts || !rh.isRetryable(bw.status) {
+			bw.flush(w)
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			bw.flush(w)
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (rh *retryHandler) isRetryable(status int) bool {
+	for _, code := range rh.statusCodes {
+		if code == status {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryAfter is HTTP middleware that adds a Retry-After header to 429 Too
+// Many Requests and 503 Service Unavailable responses that lack one, so that
+// clients back off instead of retrying immediately. after is called with the
+// request and the response status to compute the delay, which is rounded up
+// to whole seconds; a delay of zero or less leaves the response unchanged.
+//
+// Example:
+//
+//  backoff := func(r *http.Request, status int) time.Duration { return 30 * time.Second }
+//  http.ListenAndServe(":1123", handlers.RetryAfter(backoff)(r))
+func RetryAfter(after func(r *http.Request, status int) time.Duration) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := w.Header()
+			ww, done := onWriteHeader(w, func(status int) {
+				if status != http.StatusTooManyRequests && status != http.StatusServiceUnavailable {
+					return
+				}
+				if header.Get("Retry-After") != "" {
+					return
+				}
+				if d := after(r, status); d > 0 {
+					header.Set("Retry-After", strconv.Itoa(int(math.Ceil(d.Seconds()))))
+				}
+			})
+			h.ServeHTTP(ww, r)
+			done()
+		})
+	}
+}
+
+// StaticRetryAfter returns a function for RetryAfter that always suggests
+// waiting d.
+func StaticRetryAfter(d time.Duration) func(r *http.Request, status int) time.Duration {
+	return func(*http.Request, int) time.Duration {
+		return d
+	}
+}
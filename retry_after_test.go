@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	handler := RetryAfter(StaticRetryAfter(1500 * time.Millisecond))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/limited":
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+		case "/preset":
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/error":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+
+	tests := []struct {
+		path       string
+		status     int
+		retryAfter string
+	}{
+		{"/unavailable", http.StatusServiceUnavailable, "2"},
+		{"/limited", http.StatusTooManyRequests, "2"},
+		{"/preset", http.StatusServiceUnavailable, "120"},
+		{"/error", http.StatusInternalServerError, ""},
+		{"/", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, newRequest("GET", tt.path))
+
+		if got, want := rr.Code, tt.status; got != want {
+			t.Fatalf("bad status for %s: got %v want %v", tt.path, got, want)
+		}
+		if got, want := rr.Header().Get("Retry-After"), tt.retryAfter; got != want {
+			t.Fatalf("bad Retry-After for %s: got %q want %q", tt.path, got, want)
+		}
+	}
+}
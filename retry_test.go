@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRetrySucceedsAfterUnavailable(t *testing.T) {
+	calls := 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := ioutil.ReadAll(r.Body)
+		if got, want := string(body), "payload"; got != want {
+			t.Fatalf("bad body on attempt %d: got %q want %q", calls, got, want)
+		}
+		if calls < 3 {
+			w.Header().Set("X-Attempt", "failed")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+
+	r, _ := http.NewRequest("PUT", "/", strings.NewReader("payload"))
+	rr := httptest.NewRecorder()
+
+	Retry(RetryAttempts(3), RetryBackoff(0))(handler).ServeHTTP(rr, r)
+
+	if got, want := calls, 3; got != want {
+		t.Fatalf("bad call count: got %v want %v", got, want)
+	}
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+	if got, want := rr.Body.String(), "ok"; got != want {
+		t.Fatalf("bad body: got %q want %q", got, want)
+	}
+	if got := rr.Header().Get("X-Attempt"); got != "" {
+		t.Fatalf("headers from failed attempt leaked: got %q", got)
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	calls := 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	rr := httptest.NewRecorder()
+	Retry(RetryAttempts(2), RetryBackoff(0))(handler).ServeHTTP(rr, newRequest("GET", "/"))
+
+	if got, want := calls, 3; got != want {
+		t.Fatalf("bad call count: got %v want %v", got, want)
+	}
+	if got, want := rr.Code, http.StatusBadGateway; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+}
+
+func TestRetrySkipsNonIdempotentMethods(t *testing.T) {
+	calls := 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	rr := httptest.NewRecorder()
+	Retry(RetryBackoff(0))(handler).ServeHTTP(rr, newRequest("POST", "/"))
+
+	if got, want := calls, 1; got != want {
+		t.Fatalf("bad call count: got %v want %v", got, want)
+	}
+	if got, want := rr.Code, http.StatusServiceUnavailable; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+}
+
+func TestRetryIsolatesAttempts(t *testing.T) {
+	calls := 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if got, want := r.Header.Get("Accept-Encoding"), "gzip"; got != want {
+			t.Fatalf("bad header on attempt %d: got %q want %q", calls, got, want)
+		}
+		if got, want := r.URL.Path, "/items"; got != want {
+			t.Fatalf("bad path on attempt %d: got %q want %q", calls, got, want)
+		}
+		r.Header.Del("Accept-Encoding")
+		r.URL.Path = "/changed"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	r := newRequest("GET", "/items")
+	r.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+
+	Retry(RetryAttempts(2), RetryBackoff(0))(handler).ServeHTTP(rr, r)
+
+	if got, want := calls, 3; got != want {
+		t.Fatalf("bad call count: got %v want %v", got, want)
+	}
+}
+
+func TestRetryMaxBody(t *testing.T) {
+	calls := 0
+	var received string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := ioutil.ReadAll(r.Body)
+		received = string(body)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	payload := strings.Repeat("a", 16)
+	for _, known := range []bool{true, false} {
+		calls, received = 0, ""
+		r, _ := http.NewRequest("PUT", "/", strings.NewReader(payload))
+		if !known {
+			r.ContentLength = -1
+		}
+		rr := httptest.NewRecorder()
+
+		Retry(RetryAttempts(2), RetryBackoff(0), RetryMaxBody(8))(handler).ServeHTTP(rr, r)
+
+		if got, want := calls, 1; got != want {
+			t.Fatalf("bad call count: got %v want %v", got, want)
+		}
+		if received != payload {
+			t.Fatalf("bad body: got %q want %q", received, payload)
+		}
+		if got, want := rr.Code, http.StatusServiceUnavailable; got != want {
+			t.Fatalf("bad status: got %v want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+)
+
+const defaultSingleFlightMaxBody = 1 << 20
+
+// SingleFlightOption provides a functional approach to configure the
+// SingleFlight middleware.
+type SingleFlightOption func(*singleFlightHandler)
+
+type singleFlightHandler struct {
+	h       http.Handler
+	key     func(r *http.Request) string
+	maxBody int
+
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// flightCall is a request being served on behalf of several clients.
+type flightCall struct {
+	done   chan struct{}
+	resp   *bufferedResponseWriter
+	shared bool
+}
+
+// SingleFlight is HTTP middleware that coalesces concurrent identical GET and
+// HEAD requests: while one request is being served, requests with the same
+// key wait for it and receive a copy of its response. Requests are identical
+// when they have the same method, path and query by default.
+//
+// Only use SingleFlight for responses that do not depend on who makes the
+// request, or include the distinguishing inputs, such as the Authorization
+// header, in the key. Responses with a body larger than 1MB are not shared;
+// waiting requests are then served separately.
+//
+// Example:
+//
+//  r := mux.NewRouter()
+//  r.HandleFunc("/reports", ExpensiveReportHandler)
+//
+//  http.ListenAndServe(":1123", handlers.SingleFlight()(r))
+func SingleFlight(opts ...SingleFlightOption) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		sh := &singleFlightHandler{
+			h:       h,
+			key:     singleFlightKey,
+			maxBody: defaultSingleFlightMaxBody,
+			calls:   make(map[string]*flightCall),
+		}
+
+		for _, option := range opts {
+			option(sh)
+		}
+
+		return sh
+	}
+}
+
+// SingleFlightKey sets the function that maps requests to the key under which
+// they are coalesced.
+func SingleFlightKey(fn func(r *http.Request) string) SingleFlightOption {
+	return func(sh *singleFlightHandler) {
+		sh.key = fn
+	}
+}
+
+// SingleFlightMaxBody sets the size in bytes of the largest response body
+// that is shared between requests.
+func SingleFlightMaxBody(n int) SingleFlightOption {
+	return func(sh *singleFlightHandler) {
+		sh.maxBody = n
+	}
+}
+
+func singleFlightKey(r *http.Request) string {
+	return r.Method + " " + r.URL.RequestURI()
+}
+
+func (sh *singleFlightHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		sh.h.ServeHTTP(w, r)
+		return
+	}
+
+	key := sh.key(r)
+
+	sh.mu.Lock()
+	if call, ok := sh.calls[key]; ok {
+		sh.mu.Unlock()
+
+		select {
+		case <-call.done:
+		case <-r.Context().Done():
+			return
+		}
+
+		if !call.shared {
+			sh.h.ServeHTTP(w, r)
+			return
+		}
+
+		writeFlightResponse(w, call.resp)
+		return
+	}
+
+	call := &flightCall{done: make(chan struct{})}
+	sh.calls[key] = call
+	sh.mu.Unlock()
+
+	defer func() {
+		sh.mu.Lock()
+		delete(sh.calls, key)
+		sh.mu.Unlock()
+		close(call.done)
+	}()
+
+	bw := newBufferedResponseWriter()
+	sh.h.ServeHTTP(bw, r)
+
+	if bw.status == 0 {
+		bw.status = http.StatusOK
+	}
+	call.resp = bw
+	call.shared = bw.body.Len() <= sh.maxBody
+
+	writeFlightResponse(w, bw)
+}
+
+// writeFlightResponse copies a shared response to w.
+func writeFlightResponse(w http.ResponseWriter, resp *bufferedResponseWriter) {
+	for k, v := range resp.header {
+		w.Header()[k] = append([]string(nil), v...)
+	}
+	w.WriteHeader(resp.status)
+	w.Write(resp.body.Bytes())
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSingleFlight(t *testing.T) {
+	const n = 10
+
+	var calls int32
+	release := make(chan struct{})
+	handler := SingleFlight()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		w.Header().Set("X-Report", "1")
+		w.Write([]byte("report"))
+	}))
+
+	var wg sync.WaitGroup
+	recorders := make([]*httptest.ResponseRecorder, n)
+	for i := range recorders {
+		recorders[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(rr *httptest.ResponseRecorder) {
+			defer wg.Done()
+			handler.ServeHTTP(rr, newRequest("GET", "/report?id=1"))
+		}(recorders[i])
+	}
+
+	// Give every request time to join the flight before it completes.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got, want := atomic.LoadInt32(&calls), int32(1); got != want {
+		t.Fatalf("bad call count: got %v want %v", got, want)
+	}
+	for i, rr := range recorders {
+		if rr.Code != http.StatusOK || rr.Body.String() != "report" || rr.Header().Get("X-Report") != "1" {
+			t.Fatalf("request %d: bad response: %v %q %v", i, rr.Code, rr.Body.String(), rr.Header())
+		}
+	}
+}
+
+func TestSingleFlightSkipsOtherMethodsAndLargeBodies(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	handler := SingleFlight(SingleFlightMaxBody(2))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		w.Write([]byte("too large"))
+	}))
+
+	var wg sync.WaitGroup
+	for _, method := range []string{"GET", "GET", "POST", "POST"} {
+		wg.Add(1)
+		go func(method string) {
+			defer wg.Done()
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, newRequest(method, "/report"))
+			if got, want := rr.Body.String(), "too large"; got != want {
+				t.Errorf("bad body: got %q want %q", got, want)
+			}
+		}(method)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got, want := atomic.LoadInt32(&calls), int32(4); got != want {
+		t.Fatalf("bad call count: got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+// SlowRequestWarn is HTTP middleware that measures how long the wrapped
+// handler takes and calls fn when it exceeds threshold. If fn is nil, a
+// warning is written with the standard logger instead. The response is left
+// untouched.
+//
+// fn is also called for handlers that panic, so SlowRequestWarn may be placed
+// inside a RecoveryHandler and still report slow requests that fail.
+//
+// Example:
+//
+//  r := mux.NewRouter()
+//  r.HandleFunc("/", ReportHandler)
+//
+//  slow := handlers.SlowRequestWarn(time.Second, nil)
+//  http.ListenAndServe(":1123", handlers.RecoveryHandler()(slow(r)))
+func SlowRequestWarn(threshold time.Duration, fn func(r *http.Request, d time.Duration)) func(http.Handler) http.Handler {
+	if fn == nil {
+		fn = func(r *http.Request, d time.Duration) {
+			log.Printf("slow request: %s %s took %v", r.Method, r.URL.Path, d)
+		}
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			defer func() {
+				if d := time.Since(start); d > threshold {
+					fn(r, d)
+				}
+			}()
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
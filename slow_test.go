@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSlowRequestWarn(t *testing.T) {
+	var reported time.Duration
+	calls := 0
+	warn := SlowRequestWarn(10*time.Millisecond, func(r *http.Request, d time.Duration) {
+		calls++
+		reported = d
+	})
+
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	})
+
+	warn(okHandler).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "/"))
+	if calls != 0 {
+		t.Fatalf("bad call count: got %d want 0", calls)
+	}
+
+	warn(slow).ServeHTTP(httptest.NewRecorder(), newRequest("GET", "/"))
+	if calls != 1 {
+		t.Fatalf("bad call count: got %d want 1", calls)
+	}
+	if reported < 20*time.Millisecond {
+		t.Fatalf("bad duration: got %v want at least %v", reported, 20*time.Millisecond)
+	}
+}
+
+func TestSlowRequestWarnWithRecovery(t *testing.T) {
+	calls := 0
+	warn := SlowRequestWarn(10*time.Millisecond, func(r *http.Request, d time.Duration) {
+		calls++
+	})
+
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		panic("Unexpected error!")
+	})
+
+	rr := httptest.NewRecorder()
+	RecoveryHandler(RecoveryLogger(discardLogger{}))(warn(panicking)).ServeHTTP(rr, newRequest("GET", "/"))
+
+	if calls != 1 {
+		t.Fatalf("bad call count: got %d want 1", calls)
+	}
+	if got, want := rr.Code, http.StatusInternalServerError; got != want {
+		t.Fatalf("bad status: got %v want %v", got, want)
+	}
+}
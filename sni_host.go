@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// SNIHostCheck is HTTP middleware that responds with 421 Misdirected Request
+// when the server name a client sent in the TLS handshake (SNI) does not
+// match the Host of the request, which is a sign of domain fronting. The
+// comparison ignores case, the port and a trailing dot.
+//
+// Requests not received over TLS, or whose client sent no SNI, are not
+// checked. Requests whose Host or server name is in exempt are not checked
+// either, for example for a hostname shared by several certificates.
+//
+// Example:
+//
+//  http.ListenAndServeTLS(":443", "cert.pem", "key.pem", handlers.SNIHostCheck(nil)(r))
+func SNIHostCheck(exempt []string) func(http.Handler) http.Handler {
+	exemptHosts := make([]string, 0, len(exempt))
+	for _, host := range exempt {
+		exemptHosts = append(exemptHosts, normalizeHost(host))
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS == nil || r.TLS.ServerName == "" {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			sni, host := normalizeHost(r.TLS.ServerName), normalizeHost(r.Host)
+			if sni != host && !isMatch(sni, exemptHosts) && !isMatch(host, exemptHosts) {
+				http.Error(w, http.StatusText(http.StatusMisdirectedRequest), http.StatusMisdirectedRequest)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// normalizeHost lowercases host and removes any port and trailing dot.
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSNIHostCheck(t *testing.T) {
+	handler := SNIHostCheck([]string{"shared.example.com"})(okHandler)
+
+	tests := []struct {
+		name       string
+		tls        bool
+		serverName string
+		host       string
+		status     int
+	}{
+		{"matching", true, "api.example.com", "api.example.com", http.StatusOK},
+		{"matching with port and case", true, "api.example.com", "API.example.com.:443", http.StatusOK},
+		{"mismatching", true, "api.example.com", "admin.example.com", http.StatusMisdirectedRequest},
+		{"exempt", true, "shared.example.com", "admin.example.com", http.StatusOK},
+		{"no SNI", true, "", "admin.example.com", http.StatusOK},
+		{"no TLS", false, "", "admin.example.com", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("GET", "https://"+tt.host+"/")
+		r.Host = tt.host
+		if tt.tls {
+			r.TLS = &tls.ConnectionState{ServerName: tt.serverName}
+		}
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.status; got != want {
+			t.Fatalf("%s: bad status: got %v want %v", tt.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// StatusCounts holds the number of responses sent in each class of status
+// codes.
+type StatusCounts struct {
+	Informational uint64 // 1xx
+	Success       uint64 // 2xx
+	Redirection   uint64 // 3xx
+	ClientError   uint64 // 4xx
+	ServerError   uint64 // 5xx
+}
+
+// StatusCounter counts the responses sent by the handlers it wraps by status
+// code class, for lightweight self-monitoring without a metrics dependency.
+// It is safe for concurrent use.
+//
+// Example:
+//
+//  counter := handlers.NewStatusCounter()
+//  http.Handle("/", counter.Handler(r))
+//  http.HandleFunc("/debug/status", func(w http.ResponseWriter, r *http.Request) {
+//  	json.NewEncoder(w).Encode(counter.Snapshot())
+//  })
+type StatusCounter struct {
+	// counts is indexed by the first digit of the status code, minus one. It
+	// comes first to keep it 64-bit aligned for atomic access.
+	counts [5]uint64
+}
+
+// NewStatusCounter returns a StatusCounter with all counts at zero.
+func NewStatusCounter() *StatusCounter {
+	return &StatusCounter{}
+}
+
+// Handler is HTTP middleware that counts the status of every response sent
+// by h. Responses for which h panics without writing a status are counted as
+// 500 Internal Server Error.
+func (sc *StatusCounter) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger, w := makeLogger(w)
+		logger.status = 0
+
+		completed := false
+		defer func() {
+			if class := logger.finalStatus(completed)/100 - 1; class >= 0 && class < len(sc.counts) {
+				atomic.AddUint64(&sc.counts[class], 1)
+			}
+		}()
+
+		h.ServeHTTP(w, r)
+		completed = true
+	})
+}
+
+// Snapshot returns the current counts.
+func (sc *StatusCounter) Snapshot() StatusCounts {
+	return StatusCounts{
+		Informational: atomic.LoadUint64(&sc.counts[0]),
+		Success:       atomic.LoadUint64(&sc.counts[1]),
+		Redirection:   atomic.LoadUint64(&sc.counts[2]),
+		ClientError:   atomic.LoadUint64(&sc.counts[3]),
+		ServerError:   atomic.LoadUint64(&sc.counts[4]),
+	}
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatusCounter(t *testing.T) {
+	counter := NewStatusCounter()
+	handler := counter.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			w.Write([]byte("ok"))
+		case "/created":
+			w.WriteHeader(http.StatusCreated)
+		case "/moved":
+			http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+		case "/missing":
+			http.NotFound(w, r)
+		case "/error":
+			w.WriteHeader(http.StatusBadGateway)
+		case "/panic":
+			panic("boom")
+		}
+	}))
+
+	for _, path := range []string{"/ok", "/ok", "/created", "/moved", "/missing", "/missing", "/missing", "/error", "/panic"} {
+		func() {
+			defer func() { recover() }()
+			handler.ServeHTTP(httptest.NewRecorder(), newRequest("GET", path))
+		}()
+	}
+
+	want := StatusCounts{Success: 3, Redirection: 1, ClientError: 3, ServerError: 2}
+	if got := counter.Snapshot(); got != want {
+		t.Fatalf("bad snapshot: got %+v want %+v", got, want)
+	}
+}
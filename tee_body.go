@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// BodySink receives the captured request body once a request has been
+// served. truncated reports whether the body was longer than the capture
+// limit.
+type BodySink func(r *http.Request, body []byte, truncated bool)
+
+// TeeBody is HTTP middleware that captures a copy of up to maxBytes of every
+// request body and passes it to sink after the wrapped handler returns. The
+// body is still streamed to the handler unchanged, so large and chunked
+// uploads are not held in memory.
+//
+// Only the part of the body the handler reads is captured. Requests without
+// a body are not passed to sink.
+//
+// Example:
+//
+//  sink := func(r *http.Request, body []byte, truncated bool) {
+//  	log.Printf("%s %s: %q (truncated: %v)", r.Method, r.URL.Path, body, truncated)
+//  }
+//
+//  http.ListenAndServe(":1123", handlers.TeeBody(sink, 4096)(r))
+func TeeBody(sink BodySink, maxBytes int64) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body == nil || r.Body == http.NoBody {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			tb := &teeBody{ReadCloser: r.Body, max: maxBytes}
+			r.Body = tb
+			defer func() {
+				sink(r, tb.buf.Bytes(), tb.truncated)
+			}()
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// teeBody copies up to max bytes read from the wrapped body into buf.
+type teeBody struct {
+	io.ReadCloser
+	buf       bytes.Buffer
+	max       int64
+	truncated bool
+}
+
+func (t *teeBody) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	if n > 0 {
+		if remaining := t.max - int64(t.buf.Len()); remaining < int64(n) {
+			if remaining > 0 {
+				t.buf.Write(p[:remaining])
+			}
+			t.truncated = true
+		} else {
+			t.buf.Write(p[:n])
+		}
+	}
+
+	return n, err
+}
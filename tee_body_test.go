@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTeeBody(t *testing.T) {
+	tests := []struct {
+		body      string
+		captured  string
+		truncated bool
+	}{
+		{"small", "small", false},
+		{"exactly10!", "exactly10!", false},
+		{"a much larger body", "a much lar", true},
+	}
+
+	for _, tt := range tests {
+		var (
+			captured  string
+			truncated bool
+			calls     int
+		)
+		sink := func(r *http.Request, body []byte, trunc bool) {
+			calls++
+			captured = string(body)
+			truncated = trunc
+		}
+
+		handler := TeeBody(sink, 10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(body); got != tt.body {
+				t.Fatalf("bad body in handler: got %q want %q", got, tt.body)
+			}
+		}))
+
+		r, _ := http.NewRequest("POST", "/", strings.NewReader(tt.body))
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+
+		if calls != 1 {
+			t.Fatalf("bad sink call count: got %v want %v", calls, 1)
+		}
+		if captured != tt.captured || truncated != tt.truncated {
+			t.Fatalf("bad capture: got %q (truncated %v) want %q (truncated %v)", captured, truncated, tt.captured, tt.truncated)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"crypto/tls"
+	"net/http"
+	"strings"
+)
+
+// DefaultTLSVersionHeader is the header read by MinTLSVersion when
+// TrustForwardedTLSVersion is set without a header name.
+const DefaultTLSVersionHeader = "X-Forwarded-TLS-Version"
+
+// TLSVersionOption provides a functional approach to configure the
+// MinTLSVersion middleware.
+type TLSVersionOption func(*tlsVersionHandler)
+
+type tlsVersionHandler struct {
+	h               http.Handler
+	minVersion      uint16
+	forwardedHeader string
+}
+
+var tlsVersionNames = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// MinTLSVersion is HTTP middleware that responds with 403 Forbidden to
+// requests received over a TLS connection older than minVersion, such as
+// tls.VersionTLS12. Requests that were not received over TLS at all are
+// rejected too.
+//
+// Example:
+//
+//  r := mux.NewRouter()
+//  r.HandleFunc("/", PaymentHandler)
+//
+//  http.ListenAndServeTLS(":443", "cert.pem", "key.pem", handlers.MinTLSVersion(tls.VersionTLS12)(r))
+func MinTLSVersion(minVersion uint16, opts ...TLSVersionOption) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		th := &tlsVersionHandler{
+			h:          h,
+			minVersion: minVersion,
+		}
+
+		for _, option := range opts {
+			option(th)
+		}
+
+		return th
+	}
+}
+
+// TrustForwardedTLSVersion makes MinTLSVersion read the TLS version from the
+// named request header, for servers behind a proxy that terminates TLS. The
+// header holds a version such as "1.2" or "TLSv1.2". An empty name uses
+// X-Forwarded-TLS-Version.
+//
+// Only use this option when the proxy always sets the header, since clients
+// can otherwise supply it themselves.
+func TrustForwardedTLSVersion(header string) TLSVersionOption {
+	return func(th *tlsVersionHandler) {
+		if header == "" {
+			header = DefaultTLSVersionHeader
+		}
+		th.forwardedHeader = header
+	}
+}
+
+func (th *tlsVersionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if version, ok := th.version(r); !ok || version < th.minVersion {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	th.h.ServeHTTP(w, r)
+}
+
+// version returns the TLS version the request was received over.
+func (th *tlsVersionHandler) version(r *http.Request) (uint16, bool) {
+	if th.forwardedHeader != "" {
+		if value := r.Header.Get(th.forwardedHeader); value != "" {
+			return parseTLSVersion(value)
+		}
+	}
+
+	if r.TLS == nil {
+		return 0, false
+	}
+
+	return r.TLS.Version, true
+}
+
+func parseTLSVersion(value string) (uint16, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	value = strings.TrimPrefix(value, "tls")
+	value = strings.TrimSpace(strings.TrimPrefix(value, "v"))
+
+	version, ok := tlsVersionNames[value]
+	return version, ok
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMinTLSVersion(t *testing.T) {
+	handler := MinTLSVersion(tls.VersionTLS12)(okHandler)
+
+	tests := []struct {
+		state  *tls.ConnectionState
+		status int
+	}{
+		{&tls.ConnectionState{Version: tls.VersionTLS10}, http.StatusForbidden},
+		{&tls.ConnectionState{Version: tls.VersionTLS11}, http.StatusForbidden},
+		{&tls.ConnectionState{Version: tls.VersionTLS12}, http.StatusOK},
+		{&tls.ConnectionState{Version: tls.VersionTLS13}, http.StatusOK},
+		{nil, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("GET", "https://www.example.com/")
+		r.TLS = tt.state
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.status; got != want {
+			t.Fatalf("bad status for %+v: got %v want %v", tt.state, got, want)
+		}
+	}
+}
+
+func TestMinTLSVersionForwardedHeader(t *testing.T) {
+	tests := []struct {
+		opts   []TLSVersionOption
+		value  string
+		status int
+	}{
+		{nil, "1.3", http.StatusForbidden},
+		{[]TLSVersionOption{TrustForwardedTLSVersion("")}, "1.3", http.StatusOK},
+		{[]TLSVersionOption{TrustForwardedTLSVersion("")}, "TLSv1.2", http.StatusOK},
+		{[]TLSVersionOption{TrustForwardedTLSVersion("")}, "TLSv1.1", http.StatusForbidden},
+		{[]TLSVersionOption{TrustForwardedTLSVersion("")}, "bogus", http.StatusForbidden},
+		{[]TLSVersionOption{TrustForwardedTLSVersion("X-TLS")}, "1.0", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		r := newRequest("GET", "http://www.example.com/")
+		r.Header.Set(DefaultTLSVersionHeader, tt.value)
+		r.Header.Set("X-TLS", "1.2")
+		rr := httptest.NewRecorder()
+
+		MinTLSVersion(tls.VersionTLS12, tt.opts...)(okHandler).ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.status; got != want {
+			t.Fatalf("bad status for %q: got %v want %v", tt.value, got, want)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UserAgentAction is what UserAgentFilter does with a request whose
+// User-Agent matches a rule.
+type UserAgentAction int
+
+const (
+	// UserAgentLog logs the request and serves it as usual.
+	UserAgentLog UserAgentAction = iota
+	// UserAgentBlock logs the request and responds with 403 Forbidden.
+	UserAgentBlock
+	// UserAgentThrottle allows each client at most Limit matching requests
+	// per Period and responds to the rest with 429 Too Many Requests.
+	UserAgentThrottle
+)
+
+// UserAgentRule matches the User-Agent header of requests for
+// UserAgentFilter.
+type UserAgentRule struct {
+	// Pattern is matched as a case-insensitive substring of the User-Agent,
+	// or as a regular expression if Regexp is true.
+	Pattern string
+	Regexp  bool
+	Action  UserAgentAction
+	// Limit and Period configure the UserAgentThrottle action.
+	Limit  int
+	Period time.Duration
+}
+
+// UserAgentOption provides a functional approach to configure the
+// UserAgentFilter middleware.
+type UserAgentOption func(*userAgentFilter)
+
+type userAgentRule struct {
+	UserAgentRule
+	re    *regexp.Regexp
+	store RateLimitStore
+}
+
+type userAgentFilter struct {
+	h      http.Handler
+	rules  []userAgentRule
+	logger RecoveryHandlerLogger
+}
+
+// UserAgentFilter is HTTP middleware that logs, blocks or throttles requests
+// whose User-Agent header matches one of rules. The first matching rule
+// applies; requests matching no rule are served as usual. Regular expressions
+// are compiled once. An error is returned if a rule has an empty or invalid
+// pattern, or is a UserAgentThrottle rule without a positive Limit and
+// Period.
+//
+// Throttled requests are counted per client IP address and rule in memory,
+// as with RateLimit.
+//
+// Example:
+//
+//  filter, err := handlers.UserAgentFilter([]handlers.UserAgentRule{
+//  	{Pattern: "BadBot", Action: handlers.UserAgentBlock},
+//  	{Pattern: `^python-requests/`, Regexp: true, Action: handlers.UserAgentThrottle, Limit: 10, Period: time.Minute},
+//  })
+//  if err != nil {
+//  	log.Fatal(err)
+//  }
+//  http.ListenAndServe(":1123", filter(r))
+func UserAgentFilter(rules []UserAgentRule, opts ...UserAgentOption) (func(http.Handler) http.Handler, error) {
+	compiled := make([]userAgentRule, 0, len(rules))
+	for _, rule := range rules {
+		if rule.Pattern == "" {
+			return nil, errors.New("handlers: empty user agent pattern")
+		}
+		if rule.Action == UserAgentThrottle && (rule.Limit < 1 || rule.Period <= 0) {
+			return nil, fmt.Errorf("handlers: user agent rule %q throttles to %d requests per %v; limit must be at least 1 and period positive", rule.Pattern, rule.Limit, rule.Period)
+		}
+
+		ur := userAgentRule{UserAgentRule: rule}
+		if rule.Regexp {
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, err
+			}
+			ur.re = re
+		} else {
+			ur.Pattern = strings.ToLower(rule.Pattern)
+		}
+		if rule.Action == UserAgentThrottle {
+			ur.store = NewMemoryRateLimitStore(rule.Limit, rule.Period)
+		}
+		compiled = append(compiled, ur)
+	}
+
+	return func(h http.Handler) http.Handler {
+		uf := &userAgentFilter{
+			h:     h,
+			rules: compiled,
+		}
+
+		for _, option := range opts {
+			option(uf)
+		}
+
+		return uf
+	}, nil
+}
+
+// UserAgentLogger is a functional option to override the default logger used
+// to report matching requests.
+func UserAgentLogger(logger RecoveryHandlerLogger) UserAgentOption {
+	return func(uf *userAgentFilter) {
+		uf.logger = logger
+	}
+}
+
+func (uf *userAgentFilter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rule := uf.match(r.UserAgent())
+	if rule == nil {
+		uf.h.ServeHTTP(w, r)
+		return
+	}
+
+	switch rule.Action {
+	case UserAgentBlock:
+		uf.log("handlers: blocked request from user agent", strconv.Quote(r.UserAgent()))
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	case UserAgentThrottle:
+		allowed, retryAfter := rule.store.Take(clientIP(r), time.Now())
+		if !allowed {
+			uf.log("handlers: throttled request from user agent", strconv.Quote(r.UserAgent()))
+			writeTooManyRequests(w, retryAfter)
+			return
+		}
+	default:
+		uf.log("handlers: request from user agent", strconv.Quote(r.UserAgent()))
+	}
+
+	uf.h.ServeHTTP(w, r)
+}
+
+// match returns the first rule matching userAgent, or nil.
+func (uf *userAgentFilter) match(userAgent string) *userAgentRule {
+	if userAgent == "" || len(uf.rules) == 0 {
+		return nil
+	}
+
+	lower := strings.ToLower(userAgent)
+	for i := range uf.rules {
+		rule := &uf.rules[i]
+		if rule.re != nil {
+			if rule.re.MatchString(userAgent) {
+				return rule
+			}
+		} else if strings.Contains(lower, rule.Pattern) {
+			return rule
+		}
+	}
+
+	return nil
+}
+
+func (uf *userAgentFilter) log(v ...interface{}) {
+	if uf.logger != nil {
+		uf.logger.Println(v...)
+	} else {
+		log.Println(v...)
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUserAgentFilter(t *testing.T) {
+	var buf bytes.Buffer
+	filter, err := UserAgentFilter([]UserAgentRule{
+		{Pattern: "badbot", Action: UserAgentBlock},
+		{Pattern: `^curl/\d`, Regexp: true, Action: UserAgentLog},
+		{Pattern: "scraper", Action: UserAgentThrottle, Limit: 1, Period: time.Minute},
+	}, UserAgentLogger(log.New(&buf, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := filter(okHandler)
+
+	tests := []struct {
+		userAgent string
+		status    int
+		logged    bool
+	}{
+		{"Mozilla/5.0 (compatible; BadBot/2.1)", http.StatusForbidden, true},
+		{"curl/7.68.0", http.StatusOK, true},
+		{"Mozilla/5.0 (X11; Linux x86_64)", http.StatusOK, false},
+		{"", http.StatusOK, false},
+		{"Scraper/1.0", http.StatusOK, false},
+		{"Scraper/1.0", http.StatusTooManyRequests, true},
+	}
+
+	for _, tt := range tests {
+		buf.Reset()
+		r := newRequest("GET", "/")
+		r.RemoteAddr = "192.0.2.1:1234"
+		r.Header.Set("User-Agent", tt.userAgent)
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.status; got != want {
+			t.Fatalf("bad status for %q: got %v want %v", tt.userAgent, got, want)
+		}
+		if got := strings.Contains(buf.String(), tt.userAgent) && tt.userAgent != ""; got != tt.logged {
+			t.Fatalf("bad log for %q: got %q", tt.userAgent, buf.String())
+		}
+	}
+}
+
+func TestUserAgentFilterInvalidRules(t *testing.T) {
+	tests := []struct {
+		name string
+		rule UserAgentRule
+	}{
+		{"invalid regexp", UserAgentRule{Pattern: "(", Regexp: true}},
+		{"empty pattern", UserAgentRule{Action: UserAgentBlock}},
+		{"empty regexp", UserAgentRule{Regexp: true, Action: UserAgentBlock}},
+		{"zero period", UserAgentRule{Pattern: "bot", Action: UserAgentThrottle, Limit: 10}},
+		{"zero limit", UserAgentRule{Pattern: "bot", Action: UserAgentThrottle, Period: time.Minute}},
+	}
+
+	for _, tt := range tests {
+		if _, err := UserAgentFilter([]UserAgentRule{tt.rule}); err == nil {
+			t.Fatalf("%s: expected error", tt.name)
+		}
+	}
+}